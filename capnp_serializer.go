@@ -0,0 +1,270 @@
+package statemachine
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"capnproto.org/go/capnp/v3"
+	"github.com/hibrid/statemachine/serialization/capnpmodels"
+)
+
+// ContentTypeCapnp is the content type of CapnpSerialization.
+const ContentTypeCapnp = "application/x-capnp; messageType=StateObject"
+
+// capnpVersion is the version of the envelope written by
+// CapnpSerialization.
+const capnpVersion = 1
+
+// CapnpSerialization writes objects as the StateObject struct of
+// serialization/capnpmodels/state_object.capnp. Unlike JSON, integers,
+// byte slices and times in Data are read back with their Go types. Typed
+// data is written through its JSON form.
+type CapnpSerialization struct{}
+
+// ContentType returns ContentTypeCapnp.
+func (c CapnpSerialization) ContentType() string {
+	return ContentTypeCapnp
+}
+
+func (c CapnpSerialization) Serialize(so *StateObject) ([]byte, error) {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		return nil, err
+	}
+	root, err := capnpmodels.NewRootStateObject(seg)
+	if err != nil {
+		return nil, err
+	}
+	root.SetVersion(capnpVersion)
+	root.SetWrittenAt(nowFunc().UnixNano())
+	if err := root.SetId(so.ID); err != nil {
+		return nil, err
+	}
+	if err := root.SetState(so.State); err != nil {
+		return nil, err
+	}
+	if err := root.SetEventId(so.EventID); err != nil {
+		return nil, err
+	}
+
+	data := so.Data
+	if so.typed != nil {
+		if data, err = jsonMap(so.typed); err != nil {
+			return nil, err
+		}
+	}
+	if data != nil {
+		entries, err := root.NewData(int32(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		if err := setCapnpEntries(entries, data); err != nil {
+			return nil, err
+		}
+	}
+	return msg.Marshal()
+}
+
+func (c CapnpSerialization) Deserialize(data []byte) (*StateObject, error) {
+	msg, err := capnp.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	root, err := capnpmodels.ReadRootStateObject(msg)
+	if err != nil {
+		return nil, err
+	}
+	if root.Version() != capnpVersion {
+		return nil, fmt.Errorf("unsupported StateObject envelope version %d", root.Version())
+	}
+
+	so := &StateObject{}
+	if so.ID, err = root.Id(); err != nil {
+		return nil, err
+	}
+	if so.State, err = root.State(); err != nil {
+		return nil, err
+	}
+	if so.EventID, err = root.EventId(); err != nil {
+		return nil, err
+	}
+	if root.HasData() {
+		entries, err := root.Data()
+		if err != nil {
+			return nil, err
+		}
+		if so.Data, err = capnpEntries(entries); err != nil {
+			return nil, err
+		}
+	}
+	return so, nil
+}
+
+// jsonMap converts a value to a map through its JSON form.
+func jsonMap(v interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	err = json.Unmarshal(encoded, &m)
+	return m, err
+}
+
+func setCapnpEntries(entries capnpmodels.Entry_List, m map[string]interface{}) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		entry := entries.At(i)
+		if err := entry.SetKey(key); err != nil {
+			return err
+		}
+		value, err := entry.NewValue()
+		if err != nil {
+			return err
+		}
+		if err := setCapnpValue(value, m[key]); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func setCapnpValue(value capnpmodels.Value, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		value.SetKind(capnpmodels.Value_Kind_none)
+		return nil
+	case []byte:
+		value.SetKind(capnpmodels.Value_Kind_bytes)
+		return value.SetBytesValue(v)
+	case time.Time:
+		value.SetKind(capnpmodels.Value_Kind_time)
+		value.SetIntValue(v.UnixNano())
+		return nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return setCapnpValue(value, i)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return setCapnpValue(value, f)
+	case map[string]interface{}:
+		entries, err := value.NewMapValue(int32(len(v)))
+		if err != nil {
+			return err
+		}
+		value.SetKind(capnpmodels.Value_Kind_map)
+		return setCapnpEntries(entries, v)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		value.SetKind(capnpmodels.Value_Kind_bool)
+		value.SetBoolValue(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetKind(capnpmodels.Value_Kind_int)
+		value.SetIntValue(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		value.SetKind(capnpmodels.Value_Kind_uint)
+		value.SetUintValue(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		value.SetKind(capnpmodels.Value_Kind_float)
+		value.SetFloatValue(rv.Float())
+	case reflect.String:
+		value.SetKind(capnpmodels.Value_Kind_text)
+		return value.SetTextValue(rv.String())
+	case reflect.Slice, reflect.Array:
+		list, err := value.NewListValue(int32(rv.Len()))
+		if err != nil {
+			return err
+		}
+		value.SetKind(capnpmodels.Value_Kind_list)
+		for i := 0; i < rv.Len(); i++ {
+			if err := setCapnpValue(list.At(i), rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	default:
+		// Structs, pointers and maps with other keys are written through
+		// their JSON form.
+		var decoded interface{}
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			return err
+		}
+		return setCapnpValue(value, decoded)
+	}
+	return nil
+}
+
+func capnpEntries(entries capnpmodels.Entry_List) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, entries.Len())
+	for i := 0; i < entries.Len(); i++ {
+		key, err := entries.At(i).Key()
+		if err != nil {
+			return nil, err
+		}
+		value, err := entries.At(i).Value()
+		if err != nil {
+			return nil, err
+		}
+		if m[key], err = capnpValue(value); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return m, nil
+}
+
+func capnpValue(value capnpmodels.Value) (interface{}, error) {
+	switch value.Kind() {
+	case capnpmodels.Value_Kind_none:
+		return nil, nil
+	case capnpmodels.Value_Kind_bool:
+		return value.BoolValue(), nil
+	case capnpmodels.Value_Kind_int:
+		return value.IntValue(), nil
+	case capnpmodels.Value_Kind_uint:
+		return value.UintValue(), nil
+	case capnpmodels.Value_Kind_float:
+		return value.FloatValue(), nil
+	case capnpmodels.Value_Kind_text:
+		return value.TextValue()
+	case capnpmodels.Value_Kind_bytes:
+		b, err := value.BytesValue()
+		return append([]byte(nil), b...), err
+	case capnpmodels.Value_Kind_time:
+		return time.Unix(0, value.IntValue()).UTC(), nil
+	case capnpmodels.Value_Kind_list:
+		list, err := value.ListValue()
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, list.Len())
+		for i := range items {
+			if items[i], err = capnpValue(list.At(i)); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case capnpmodels.Value_Kind_map:
+		entries, err := value.MapValue()
+		if err != nil {
+			return nil, err
+		}
+		return capnpEntries(entries)
+	}
+	return nil, fmt.Errorf("unknown value kind %d", value.Kind())
+}
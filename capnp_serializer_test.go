@@ -0,0 +1,70 @@
+package statemachine
+
+import (
+	"testing"
+
+	"capnproto.org/go/capnp/v3"
+	"github.com/hibrid/statemachine/serialization/capnpmodels"
+)
+
+func TestCapnpPreservesTypes(t *testing.T) {
+	testPreservesTypes(t, CapnpSerialization{})
+}
+
+// TestCapnpEnvelope reads a serialized object with the generated schema
+// only.
+func TestCapnpEnvelope(t *testing.T) {
+	so := NewStateObject(map[string]interface{}{"iccid": "8901"}, nil, nil)
+	data, err := CapnpSerialization{}.Serialize(so)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	msg, err := capnp.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Failed to read the message: %v", err)
+	}
+	root, err := capnpmodels.ReadRootStateObject(msg)
+	if err != nil {
+		t.Fatalf("Failed to read the envelope: %v", err)
+	}
+	state, _ := root.State()
+	entries, _ := root.Data()
+	if root.Version() != capnpVersion || state != SIMNotActivated || entries.Len() != 1 {
+		t.Errorf("Unexpected envelope %v", root)
+	}
+}
+
+func benchmarkObject() *StateObject {
+	so := NewStateObject(map[string]interface{}{
+		"iccid":    "89014103211118510720",
+		"imsi":     int64(310150123456789),
+		"msisdn":   "15551234567",
+		"carriers": []interface{}{"TelecomProvider", "RoamingPartner"},
+		"plan":     map[string]interface{}{"name": "unlimited", "dataGB": 50, "roaming": true},
+	}, nil, nil)
+	so.ID, so.EventID, so.State = "sim-8901", "evt-1", SIMActivated
+	return so
+}
+
+func benchmarkRoundTrip(b *testing.B, serializer Serialization) {
+	so := benchmarkObject()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := serializer.Serialize(so)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := serializer.Deserialize(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCapnpRoundTrip(b *testing.B) {
+	benchmarkRoundTrip(b, CapnpSerialization{})
+}
+
+func BenchmarkJSONRoundTrip(b *testing.B) {
+	benchmarkRoundTrip(b, JSONSerialization{})
+}
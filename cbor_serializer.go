@@ -0,0 +1,63 @@
+package statemachine
+
+import (
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ContentTypeCBOR is the content type of CBORSerialization.
+const ContentTypeCBOR = "application/cbor"
+
+var (
+	cborEncMode, _ = cbor.EncOptions{
+		Time:    cbor.TimeRFC3339Nano,
+		TimeTag: cbor.EncTagRequired,
+	}.EncMode()
+	cborDecMode, _ = cbor.DecOptions{
+		IntDec:         cbor.IntDecConvertSignedOrBigInt,
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+)
+
+// CBORSerialization writes objects as CBOR, with the field names of
+// JSONSerialization. Unlike JSON, integers in Data are read back as int64,
+// byte slices as []byte and times, written with their tag, as time.Time.
+type CBORSerialization struct{}
+
+// ContentType returns ContentTypeCBOR.
+func (c CBORSerialization) ContentType() string {
+	return ContentTypeCBOR
+}
+
+func (c CBORSerialization) Serialize(so *StateObject) ([]byte, error) {
+	return cborEncMode.Marshal(newBinaryObject(so))
+}
+
+func (c CBORSerialization) Deserialize(data []byte) (*StateObject, error) {
+	var so StateObject
+	if err := c.DeserializeInto(data, &so); err != nil {
+		return nil, err
+	}
+	return &so, nil
+}
+
+// DeserializeInto decodes into an existing StateObject, so the data of a
+// TypedStateObject is decoded straight into its type.
+func (c CBORSerialization) DeserializeInto(data []byte, so *StateObject) error {
+	var obj struct {
+		ID      string          `json:"id,omitempty"`
+		Data    cbor.RawMessage `json:"data"`
+		State   string          `json:"state"`
+		EventID string          `json:"eventID"`
+	}
+	if err := cborDecMode.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	so.ID, so.State, so.EventID = obj.ID, obj.State, obj.EventID
+	if so.typed != nil {
+		return cborDecMode.Unmarshal(obj.Data, so.typed)
+	}
+	so.Data = nil
+	return cborDecMode.Unmarshal(obj.Data, &so.Data)
+}
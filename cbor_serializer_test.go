@@ -0,0 +1,11 @@
+package statemachine
+
+import "testing"
+
+func TestCBORPreservesTypes(t *testing.T) {
+	testPreservesTypes(t, CBORSerialization{})
+}
+
+func BenchmarkCBORRoundTrip(b *testing.B) {
+	benchmarkRoundTrip(b, CBORSerialization{})
+}
@@ -0,0 +1,242 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through and counts failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every call fast until the cooldown has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets a single trial call through to probe the
+	// dependency.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrCircuitOpen is matched by the error of a handler call rejected by an
+// open circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned instead of calling a handler whose dependency
+// is failing.
+type CircuitOpenError struct {
+	Dependency string
+	// RetryAt is when the breaker lets a trial call through again.
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open until %s", e.Dependency, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitBreakerSettings configures when a circuit breaker opens and how
+// long it stays open.
+type CircuitBreakerSettings struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker. Zero means 5.
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before a trial call.
+	// Zero means 30 seconds.
+	Cooldown time.Duration
+	// IsFailure decides which handler errors count as failures of the
+	// dependency. When nil, every failing outcome except a cancelled
+	// context does.
+	IsFailure func(error) bool
+}
+
+// CircuitBreaker fails handler calls fast while the dependency they call is
+// failing. It is shared by every transition of the StateMachine that created
+// it.
+type CircuitBreaker struct {
+	dependency string
+	settings   CircuitBreakerSettings
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// CircuitBreaker returns the circuit breaker for the dependency, creating it
+// with the settings on first use. Later calls return the same breaker and
+// ignore the settings.
+func (sm *StateMachine) CircuitBreaker(dependency string, settings CircuitBreakerSettings) *CircuitBreaker {
+	sm.breakersMu.Lock()
+	defer sm.breakersMu.Unlock()
+	if breaker, ok := sm.breakers[dependency]; ok {
+		return breaker
+	}
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 5
+	}
+	if settings.Cooldown <= 0 {
+		settings.Cooldown = 30 * time.Second
+	}
+	breaker := &CircuitBreaker{dependency: dependency, settings: settings}
+	if sm.breakers == nil {
+		sm.breakers = make(map[string]*CircuitBreaker)
+	}
+	sm.breakers[dependency] = breaker
+	return breaker
+}
+
+// State returns the current state of the breaker.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && !nowFunc().Before(cb.openedAt.Add(cb.settings.Cooldown)) {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// Wrap guards a handler with the breaker. The handler keeps its name, so it
+// can still take the place of a default slot.
+func (cb *CircuitBreaker) Wrap(h Handler) Handler {
+	return &circuitBreakerHandler{Handler: h, breaker: cb}
+}
+
+// Middleware guards every handler it wraps with the breaker.
+func (cb *CircuitBreaker) Middleware() Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return &middlewareHandler{
+			next: next,
+			handle: func(ctx context.Context, tc *TransitionContext, next ContextHandler) error {
+				return cb.call(func() error { return next.Handle(ctx, tc) })
+			},
+		}
+	}
+}
+
+// call runs fn unless the breaker is open and records its outcome.
+func (cb *CircuitBreaker) call(fn func() error) error {
+	if err := cb.allow(); err != nil {
+		return err
+	}
+	defer func() {
+		// A panic counts as a failure, so a trial call can't leave the
+		// breaker half-open forever
+		if r := recover(); r != nil {
+			cb.record(fmt.Errorf("handler panicked: %v", r))
+			panic(r)
+		}
+	}()
+	err := fn()
+	cb.record(err)
+	return err
+}
+
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		retryAt := cb.openedAt.Add(cb.settings.Cooldown)
+		if nowFunc().Before(retryAt) {
+			return &CircuitOpenError{Dependency: cb.dependency, RetryAt: retryAt}
+		}
+		cb.state = CircuitHalfOpen
+		cb.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if cb.probing {
+			return &CircuitOpenError{Dependency: cb.dependency, RetryAt: nowFunc()}
+		}
+		cb.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of a call. An error that
+// doesn't count as a failure, like a cancelled context or ErrStop, says
+// nothing about the dependency, so it only lets another trial call through.
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if err == nil {
+		cb.state = CircuitClosed
+		cb.failures = 0
+		cb.probing = false
+		return
+	}
+	if !cb.isFailure(err) {
+		cb.probing = false
+		return
+	}
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.settings.FailureThreshold {
+		cb.state = CircuitOpen
+		cb.openedAt = nowFunc()
+		cb.probing = false
+	}
+}
+
+func (cb *CircuitBreaker) isFailure(err error) bool {
+	if OutcomeOf(err) != OutcomeFail {
+		return false
+	}
+	if cb.settings.IsFailure != nil {
+		return cb.settings.IsFailure(err)
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+type circuitBreakerHandler struct {
+	Handler
+	breaker *CircuitBreaker
+}
+
+func (h *circuitBreakerHandler) Name() string {
+	return handlerSlot(h.Handler)
+}
+
+func (h *circuitBreakerHandler) Unwrap() Handler {
+	return h.Handler
+}
+
+func (h *circuitBreakerHandler) contextHandler() ContextHandler {
+	return &circuitBreakerContextHandler{handler: AdaptHandler(h.Handler), breaker: h.breaker}
+}
+
+type circuitBreakerContextHandler struct {
+	handler ContextHandler
+	breaker *CircuitBreaker
+}
+
+func (h *circuitBreakerContextHandler) Handle(ctx context.Context, tc *TransitionContext) error {
+	return h.breaker.call(func() error { return h.handler.Handle(ctx, tc) })
+}
+
+// Rollback always reaches the handler, so the work it did while the
+// breaker was closed can still be undone.
+func (h *circuitBreakerContextHandler) Rollback(ctx context.Context, tc *TransitionContext) error {
+	return h.handler.Rollback(ctx, tc)
+}
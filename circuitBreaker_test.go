@@ -0,0 +1,139 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	now := mockTime
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	breaker := sm.CircuitBreaker("billing", CircuitBreakerSettings{FailureThreshold: 2, Cooldown: time.Minute})
+	handler := &mockContextHandler{err: errCarrierUnavailable}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, breaker.Wrap(FromContextHandler(handler)))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	for i := 0; i < 2; i++ {
+		if err := so.TransitionTo(sm, SIMActivated); !errors.Is(err, errCarrierUnavailable) {
+			t.Fatalf("Expected handler error but got %v", err)
+		}
+	}
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected breaker to be open but got %s", breaker.State())
+	}
+
+	err := so.TransitionTo(sm, SIMActivated)
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected a *CircuitOpenError but got %v", err)
+	}
+	if openErr.Dependency != "billing" {
+		t.Errorf("Expected dependency billing but got %s", openErr.Dependency)
+	}
+	if handler.handled != 2 {
+		t.Errorf("Expected the open breaker to skip the handler but it ran %d times", handler.handled)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	now := mockTime
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	sm := newTestStateMachine()
+	breaker := sm.CircuitBreaker("carrier", CircuitBreakerSettings{FailureThreshold: 1, Cooldown: time.Minute})
+	failing := breaker.Middleware()(&mockContextHandler{err: errCarrierUnavailable})
+	tc := &TransitionContext{Object: &StateObject{}}
+
+	failing.Handle(context.Background(), tc)
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected breaker to be open but got %s", breaker.State())
+	}
+
+	now = now.Add(time.Minute)
+	if breaker.State() != CircuitHalfOpen {
+		t.Fatalf("Expected breaker to be half-open after the cooldown but got %s", breaker.State())
+	}
+
+	// A failing trial call opens the breaker again
+	if err := failing.Handle(context.Background(), tc); !errors.Is(err, errCarrierUnavailable) {
+		t.Fatalf("Expected the trial call to reach the handler but got %v", err)
+	}
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected breaker to open again but got %s", breaker.State())
+	}
+
+	// A successful trial call closes it
+	now = now.Add(time.Minute)
+	succeeding := breaker.Middleware()(&mockContextHandler{})
+	if err := succeeding.Handle(context.Background(), tc); err != nil {
+		t.Fatalf("Expected the trial call to succeed but got %v", err)
+	}
+	if breaker.State() != CircuitClosed {
+		t.Fatalf("Expected breaker to be closed but got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreakerIgnoresErrorsThatAreNoFailures(t *testing.T) {
+	now := mockTime
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	sm := newTestStateMachine()
+	breaker := sm.CircuitBreaker("carrier", CircuitBreakerSettings{FailureThreshold: 2, Cooldown: time.Minute})
+	failing := breaker.Middleware()(&mockContextHandler{err: errCarrierUnavailable})
+	cancelled := breaker.Middleware()(&mockContextHandler{err: context.Canceled})
+	stopping := breaker.Middleware()(&mockContextHandler{err: ErrStop})
+	tc := &TransitionContext{Object: &StateObject{}}
+
+	// A cancelled call between two failures doesn't reset the count
+	failing.Handle(context.Background(), tc)
+	cancelled.Handle(context.Background(), tc)
+	failing.Handle(context.Background(), tc)
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected breaker to be open but got %s", breaker.State())
+	}
+
+	// Nor does a trial call that stops the chain close the breaker
+	now = now.Add(time.Minute)
+	if err := stopping.Handle(context.Background(), tc); !errors.Is(err, ErrStop) {
+		t.Fatalf("Expected the trial call to reach the handler but got %v", err)
+	}
+	if breaker.State() != CircuitHalfOpen {
+		t.Fatalf("Expected breaker to stay half-open but got %s", breaker.State())
+	}
+	if err := failing.Handle(context.Background(), tc); !errors.Is(err, errCarrierUnavailable) {
+		t.Fatalf("Expected another trial call to reach the handler but got %v", err)
+	}
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected the failing trial call to open the breaker but got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreakerSharedByDependency(t *testing.T) {
+	sm := newTestStateMachine()
+	first := sm.CircuitBreaker("billing", CircuitBreakerSettings{})
+	second := sm.CircuitBreaker("billing", CircuitBreakerSettings{FailureThreshold: 1})
+	if first != second {
+		t.Fatalf("Expected one breaker per dependency")
+	}
+	if first.settings.FailureThreshold != 5 || first.settings.Cooldown != 30*time.Second {
+		t.Errorf("Expected default settings but got %+v", first.settings)
+	}
+}
+
+func TestCircuitBreakerKeepsHandlerName(t *testing.T) {
+	sm := newTestStateMachine()
+	breaker := sm.CircuitBreaker("telemetry", CircuitBreakerSettings{})
+	if slot := handlerSlot(breaker.Wrap(&CustomTelemetryHandler{})); slot != SlotTelemetry {
+		t.Errorf("Expected wrapped handler to keep slot %s but got %s", SlotTelemetry, slot)
+	}
+}
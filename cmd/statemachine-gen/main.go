@@ -0,0 +1,46 @@
+// Command statemachine-gen generates typed states and events from a machine
+// definition written in YAML or JSON. Use it with go generate:
+//
+//	//go:generate go run github.com/hibrid/statemachine/cmd/statemachine-gen -in sim.yaml -out sim_states.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hibrid/statemachine"
+	"github.com/hibrid/statemachine/codegen"
+)
+
+func main() {
+	in := flag.String("in", "", "machine definition to read")
+	out := flag.String("out", "", "Go file to write")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated file")
+	flag.Parse()
+
+	if err := run(*in, *out, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, "statemachine-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out, pkg string) error {
+	if in == "" || out == "" {
+		return fmt.Errorf("-in and -out are required")
+	}
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	def, err := statemachine.ParseDefinition(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	src, err := codegen.Generate(def, codegen.Options{Package: pkg, Source: filepath.Base(in)})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
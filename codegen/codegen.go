@@ -0,0 +1,282 @@
+// Package codegen generates typed states and events from a machine
+// definition, so a mistyped state or event fails to compile instead of
+// failing at runtime with an invalid transition.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/hibrid/statemachine"
+)
+
+// Options configures the generated file.
+type Options struct {
+	// Package is the name of the package of the generated file.
+	Package string
+	// Source names the definition in the generated header.
+	Source string
+}
+
+type enumValue struct {
+	Ident string
+	Name  string
+	Final bool
+}
+
+type templateData struct {
+	Options
+	States []enumValue
+	Events []enumValue
+}
+
+// Generate returns the Go source of typed State and Event enums for the
+// definition: String methods, parsers, visitors that must handle every
+// state or event, and a Fire function per event.
+func Generate(def *statemachine.Definition, opts Options) ([]byte, error) {
+	if opts.Package == "" {
+		return nil, fmt.Errorf("codegen: no package name")
+	}
+
+	data := templateData{Options: opts}
+	idents := make(map[string]string)
+	for _, state := range def.States {
+		ident := identifier(state.Name)
+		if other, ok := idents["State"+ident]; ok {
+			return nil, fmt.Errorf("codegen: states %s and %s both map to %s", other, state.Name, ident)
+		}
+		idents["State"+ident] = state.Name
+		data.States = append(data.States, enumValue{Ident: ident, Name: state.Name, Final: state.Final})
+	}
+
+	events := make(map[string]bool)
+	for _, t := range def.Transitions {
+		if t.Event != "" {
+			events[t.Event] = true
+		}
+	}
+	names := make([]string, 0, len(events))
+	for event := range events {
+		names = append(names, event)
+	}
+	sort.Strings(names)
+	for _, event := range names {
+		ident := identifier(event)
+		if other, ok := idents["Event"+ident]; ok {
+			return nil, fmt.Errorf("codegen: events %s and %s both map to %s", other, event, ident)
+		}
+		idents["Event"+ident] = event
+		data.Events = append(data.Events, enumValue{Ident: ident, Name: event})
+	}
+
+	var buf bytes.Buffer
+	if err := fileTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("codegen: generated invalid Go: %w", err)
+	}
+	return src, nil
+}
+
+// identifier turns a state or event name into an exported Go identifier,
+// such as "number-ported" into "NumberPorted".
+func identifier(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	ident := b.String()
+	if ident == "" || unicode.IsDigit(rune(ident[0])) {
+		ident = "X" + ident
+	}
+	return ident
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by statemachine-gen{{if .Source}} from {{.Source}}{{end}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibrid/statemachine"
+)
+
+// State is a state of the machine.
+type State int
+
+const (
+{{- range $i, $s := .States}}
+	State{{$s.Ident}}{{if eq $i 0}} State = iota + 1{{end}}
+{{- end}}
+)
+
+var stateNames = [...]string{
+	"",
+{{- range .States}}
+	{{printf "%q" .Name}},
+{{- end}}
+}
+
+// States returns every state of the machine.
+func States() []State {
+	return []State{
+{{- range .States}}
+		State{{.Ident}},
+{{- end}}
+	}
+}
+
+// String returns the name of the state as used by the StateMachine.
+func (s State) String() string {
+	if s <= 0 || int(s) >= len(stateNames) {
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+	return stateNames[s]
+}
+
+// IsFinal reports whether the state is declared final.
+func (s State) IsFinal() bool {
+	switch s {
+{{- range .States}}{{if .Final}}
+	case State{{.Ident}}:
+		return true
+{{- end}}{{end}}
+	}
+	return false
+}
+
+// ParseState returns the state with the given name.
+func ParseState(name string) (State, error) {
+	for i, stateName := range stateNames {
+		if i > 0 && stateName == name {
+			return State(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown state %q", name)
+}
+
+// CurrentState returns the state of the object.
+func CurrentState(so *statemachine.StateObject) (State, error) {
+	return ParseState(so.State)
+}
+
+// StateVisitor has a method for every state, so an implementation stops
+// compiling when a state is added.
+type StateVisitor interface {
+{{- range .States}}
+	Visit{{.Ident}}()
+{{- end}}
+}
+
+// Visit calls the method of the visitor for the state.
+func (s State) Visit(v StateVisitor) {
+	switch s {
+{{- range .States}}
+	case State{{.Ident}}:
+		v.Visit{{.Ident}}()
+{{- end}}
+	default:
+		panic(fmt.Sprintf("unknown state %d", int(s)))
+	}
+}
+
+// TransitionTo transitions the object to the state.
+func TransitionTo(ctx context.Context, sm *statemachine.StateMachine, so *statemachine.StateObject, to State) error {
+	return so.TransitionToContext(ctx, sm, to.String())
+}
+{{- if .Events}}
+
+// Event is an event of the machine.
+type Event int
+
+const (
+{{- range $i, $e := .Events}}
+	Event{{$e.Ident}}{{if eq $i 0}} Event = iota + 1{{end}}
+{{- end}}
+)
+
+var eventNames = [...]string{
+	"",
+{{- range .Events}}
+	{{printf "%q" .Name}},
+{{- end}}
+}
+
+// Events returns every event of the machine.
+func Events() []Event {
+	return []Event{
+{{- range .Events}}
+		Event{{.Ident}},
+{{- end}}
+	}
+}
+
+// String returns the name of the event as used by the StateMachine.
+func (e Event) String() string {
+	if e <= 0 || int(e) >= len(eventNames) {
+		return fmt.Sprintf("Event(%d)", int(e))
+	}
+	return eventNames[e]
+}
+
+// ParseEvent returns the event with the given name.
+func ParseEvent(name string) (Event, error) {
+	for i, eventName := range eventNames {
+		if i > 0 && eventName == name {
+			return Event(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown event %q", name)
+}
+
+// EventVisitor has a method for every event, so an implementation stops
+// compiling when an event is added.
+type EventVisitor interface {
+{{- range .Events}}
+	Visit{{.Ident}}()
+{{- end}}
+}
+
+// Visit calls the method of the visitor for the event.
+func (e Event) Visit(v EventVisitor) {
+	switch e {
+{{- range .Events}}
+	case Event{{.Ident}}:
+		v.Visit{{.Ident}}()
+{{- end}}
+	default:
+		panic(fmt.Sprintf("unknown event %d", int(e)))
+	}
+}
+
+// Fire fires the event for the object.
+func Fire(ctx context.Context, sm *statemachine.StateMachine, so *statemachine.StateObject, event Event) error {
+	return so.FireContext(ctx, sm, event.String())
+}
+{{- range .Events}}
+
+// Fire{{.Ident}} fires the {{.Name}} event for the object.
+func Fire{{.Ident}}(ctx context.Context, sm *statemachine.StateMachine, so *statemachine.StateObject) error {
+	return so.FireContext(ctx, sm, {{printf "%q" .Name}})
+}
+{{- end}}
+{{- end}}
+`))
@@ -0,0 +1,90 @@
+package codegen
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hibrid/statemachine"
+)
+
+func TestGenerate(t *testing.T) {
+	def := &statemachine.Definition{
+		Initial: "Ordered",
+		States: []statemachine.StateDefinition{
+			{Name: "Ordered"},
+			{Name: "number-ported", Final: true},
+		},
+		Transitions: []statemachine.TransitionDefinition{
+			{From: "Ordered", To: "number-ported", Event: "port"},
+			{From: "Ordered", To: "number-ported", Event: "port"},
+		},
+	}
+	src, err := Generate(def, Options{Package: "porting", Source: "porting.yaml"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"// Code generated by statemachine-gen from porting.yaml. DO NOT EDIT.",
+		"package porting",
+		"StateOrdered State = iota + 1",
+		"StateNumberPorted\n",
+		`"number-ported",`,
+		"case StateNumberPorted:\n\t\treturn true",
+		"VisitNumberPorted()",
+		"EventPort Event = iota + 1\n)",
+		"func FirePort(ctx context.Context, sm *statemachine.StateMachine, so *statemachine.StateObject) error {",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("Expected %q in\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateRejectsCollidingNames(t *testing.T) {
+	def := &statemachine.Definition{
+		States: []statemachine.StateDefinition{{Name: "number-ported"}, {Name: "NumberPorted"}},
+	}
+	if _, err := Generate(def, Options{Package: "porting"}); err == nil {
+		t.Errorf("Expected states mapping to the same identifier to be rejected")
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"SIMActivated":                         "SIMActivated",
+		"refresh-billing":                      "RefreshBilling",
+		"Provisioning(SIMReady,NumberPending)": "ProvisioningSIMReadyNumberPending",
+		"3g":                                   "X3g",
+	}
+	for name, want := range tests {
+		if got := identifier(name); got != want {
+			t.Errorf("identifier(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestExampleIsUpToDate fails when example/sim was not regenerated after a
+// change to the generator or the definition.
+func TestExampleIsUpToDate(t *testing.T) {
+	data, err := os.ReadFile("../example/sim/sim.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, err := statemachine.ParseDefinition(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Generate(def, Options{Package: "sim", Source: "sim.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkedIn, err := os.ReadFile("../example/sim/sim_states.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != string(checkedIn) {
+		t.Errorf("example/sim/sim_states.go is stale, run go generate ./example/sim")
+	}
+}
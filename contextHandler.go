@@ -0,0 +1,190 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	// ErrHandlerFailed is reported when a Handler returns false from Handle.
+	ErrHandlerFailed = errors.New("handler failed")
+	// ErrRollbackFailed is reported when a handler could not be rolled back.
+	ErrRollbackFailed = errors.New("rollback failed")
+	// ErrStop is returned, or wrapped, by a ContextHandler to end the chain
+	// successfully without running the remaining handlers.
+	ErrStop = errors.New("stop handler chain")
+	// ErrAlreadyProcessed is returned by a transition whose event was already
+	// processed. The remaining handlers are skipped and the object is left
+	// untouched, so callers can safely acknowledge the duplicate message.
+	ErrAlreadyProcessed = fmt.Errorf("%w: event already processed", ErrStop)
+)
+
+// Outcome is the result of running a handler.
+type Outcome int
+
+const (
+	// OutcomeContinue lets the chain carry on with the next handler.
+	OutcomeContinue Outcome = iota
+	// OutcomeStop ends the chain successfully.
+	OutcomeStop
+	// OutcomeFail ends the chain and rolls back the executed handlers.
+	OutcomeFail
+)
+
+// OutcomeOf classifies the error returned by a ContextHandler.
+func OutcomeOf(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeContinue
+	case errors.Is(err, ErrStop):
+		return OutcomeStop
+	default:
+		return OutcomeFail
+	}
+}
+
+// HandlerError is returned by TransitionTo when a handler fails. It names the
+// failing handler and records the outcome of the rollback that followed.
+type HandlerError struct {
+	Handler  string
+	EventID  string
+	Err      error
+	Rollback error
+	// Attempts is the number of calls made to a handler with a retry policy.
+	Attempts int
+	// ManualReview is set when the failed rollback moved the object to the
+	// ManualReview state.
+	ManualReview bool
+}
+
+func (e *HandlerError) Error() string {
+	msg := fmt.Sprintf("handler %s failed for eventID %s: %v", e.Handler, e.EventID, e.Err)
+	if e.Attempts > 1 {
+		msg = fmt.Sprintf("handler %s failed for eventID %s after %d attempts: %v", e.Handler, e.EventID, e.Attempts, e.Err)
+	}
+	if e.Rollback != nil && e.ManualReview {
+		msg += fmt.Sprintf("; failed to rollback, moving to manual review: %v", e.Rollback)
+	} else if e.Rollback != nil {
+		msg += fmt.Sprintf("; failed to rollback: %v", e.Rollback)
+	}
+	return msg
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports ErrRollbackFailed as matching when the rollback did not succeed.
+func (e *HandlerError) Is(target error) bool {
+	return target == ErrRollbackFailed && e.Rollback != nil
+}
+
+// PanicError reports a panic in a handler, recovered by TransitionTo.
+type PanicError struct {
+	Handler string
+	Value   interface{}
+	Stack   []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler %s panicked: %v", e.Handler, e.Value)
+}
+
+// callSafely calls fn and turns a panic into a *PanicError.
+func callSafely(name string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Handler: name, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// contextHandlerProvider is implemented by handlers that have a
+// context-aware implementation besides their bool based one.
+type contextHandlerProvider interface {
+	contextHandler() ContextHandler
+}
+
+// AdaptHandler turns a bool based Handler into a ContextHandler.
+func AdaptHandler(h Handler) ContextHandler {
+	if provider, ok := h.(contextHandlerProvider); ok {
+		return provider.contextHandler()
+	}
+	return legacyHandler{handler: h}
+}
+
+type legacyHandler struct {
+	handler Handler
+}
+
+func (h legacyHandler) Handle(ctx context.Context, tc *TransitionContext) error {
+	if !h.handler.Handle(tc.Object, tc.To) {
+		return ErrHandlerFailed
+	}
+	return nil
+}
+
+func (h legacyHandler) Rollback(ctx context.Context, tc *TransitionContext) error {
+	if !h.handler.Rollback(tc.Object, tc.To) {
+		return ErrRollbackFailed
+	}
+	return nil
+}
+
+// FromContextHandler wraps a ContextHandler so it can be registered with
+// RegisterTransition and chained with other handlers. TransitionTo calls the
+// wrapped handler with the transition's context.
+func FromContextHandler(h ContextHandler) Handler {
+	return &contextHandlerAdapter{handler: h}
+}
+
+type contextHandlerAdapter struct {
+	next    Handler
+	handler ContextHandler
+}
+
+func (h *contextHandlerAdapter) Handle(so *StateObject, state string) bool {
+	return h.handler.Handle(context.Background(), &TransitionContext{Object: so, From: so.State, To: state}) == nil
+}
+
+func (h *contextHandlerAdapter) Rollback(so *StateObject, state string) bool {
+	return h.handler.Rollback(context.Background(), &TransitionContext{Object: so, From: so.State, To: state}) == nil
+}
+
+// Name reports the slot of the wrapped handler when it implements Named.
+func (h *contextHandlerAdapter) Name() string {
+	if named, ok := h.handler.(Named); ok {
+		return named.Name()
+	}
+	return ""
+}
+
+func (h *contextHandlerAdapter) contextHandler() ContextHandler {
+	return h.handler
+}
+
+func (h *contextHandlerAdapter) SetNext(handler Handler) {
+	h.next = handler
+}
+
+func (h *contextHandlerAdapter) Next() Handler {
+	return h.next
+}
+
+// detachedContext keeps the values of its parent but is never cancelled, so
+// rollbacks still run after the transition's context is done.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
@@ -0,0 +1,276 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+var errCarrierUnavailable = errors.New("carrier unavailable")
+
+type mockContextHandler struct {
+	err         error
+	rollbackErr error
+	handled     int
+	rolledBack  int
+}
+
+func (h *mockContextHandler) Handle(ctx context.Context, tc *TransitionContext) error {
+	h.handled++
+	return h.err
+}
+
+func (h *mockContextHandler) Rollback(ctx context.Context, tc *TransitionContext) error {
+	h.rolledBack++
+	return h.rollbackErr
+}
+
+func registerChain(sm *StateMachine, from, to string, handlers ...Handler) {
+	sm.transitions[from+"->"+to] = &StateTransition{
+		From:  from,
+		To:    to,
+		Chain: CreateHandlerChain(handlers...),
+	}
+}
+
+func TestTransitionToContextHandlerError(t *testing.T) {
+	sm := newTestStateMachine()
+	first := &mockContextHandler{}
+	failing := &mockContextHandler{err: errCarrierUnavailable}
+	registerChain(sm, SIMNotActivated, SIMActivated, FromContextHandler(first), FromContextHandler(failing))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	if !errors.Is(err, errCarrierUnavailable) {
+		t.Fatalf("Expected error to wrap the handler error but got %v", err)
+	}
+
+	var handlerErr *HandlerError
+	if !errors.As(err, &handlerErr) {
+		t.Fatalf("Expected a *HandlerError but got %T", err)
+	}
+	if handlerErr.Handler != "*statemachine.mockContextHandler" {
+		t.Errorf("Expected failing handler to be identified but got %s", handlerErr.Handler)
+	}
+	if handlerErr.Rollback != nil || errors.Is(err, ErrRollbackFailed) {
+		t.Errorf("Expected rollback to succeed but got %v", handlerErr.Rollback)
+	}
+	if first.rolledBack != 1 || failing.rolledBack != 0 {
+		t.Errorf("Expected only the executed handler to be rolled back")
+	}
+	if so.State != SIMNotActivated {
+		t.Errorf("Expected state to stay %s but got %s", SIMNotActivated, so.State)
+	}
+}
+
+func TestTransitionToRollbackFailure(t *testing.T) {
+	sm := newTestStateMachine()
+	first := &mockContextHandler{rollbackErr: errors.New("cannot refund")}
+	failing := &mockContextHandler{err: errCarrierUnavailable}
+	registerChain(sm, SIMNotActivated, SIMActivated, FromContextHandler(first), FromContextHandler(failing))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	if !errors.Is(err, ErrRollbackFailed) {
+		t.Fatalf("Expected rollback failure to be reported but got %v", err)
+	}
+	if so.State != ManualReview {
+		t.Errorf("Expected state to be %s but got %s", ManualReview, so.State)
+	}
+}
+
+func TestTransitionToLegacyHandlerFailure(t *testing.T) {
+	sm := newTestStateMachine()
+	registerChain(sm, SIMNotActivated, SIMActivated, &MockHandler{}, &failingHandler{})
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	if !errors.Is(err, ErrHandlerFailed) {
+		t.Fatalf("Expected ErrHandlerFailed but got %v", err)
+	}
+}
+
+func TestTransitionToContextCancelled(t *testing.T) {
+	sm := newTestStateMachine()
+	handler := &mockContextHandler{}
+	registerChain(sm, SIMNotActivated, SIMActivated, FromContextHandler(handler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionToContext(ctx, sm, SIMActivated)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled but got %v", err)
+	}
+	if handler.handled != 0 {
+		t.Errorf("Handler should not run once the context is cancelled")
+	}
+}
+
+type failingHandler struct {
+	MockHandler
+}
+
+func (h *failingHandler) Handle(so *StateObject, state string) bool {
+	return false
+}
+
+func TestOutcomeOf(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected Outcome
+	}{
+		{nil, OutcomeContinue},
+		{ErrStop, OutcomeStop},
+		{ErrAlreadyProcessed, OutcomeStop},
+		{errCarrierUnavailable, OutcomeFail},
+	}
+	for _, c := range cases {
+		if outcome := OutcomeOf(c.err); outcome != c.expected {
+			t.Errorf("Expected outcome %d for %v but got %d", c.expected, c.err, outcome)
+		}
+	}
+}
+
+func TestTransitionToAlreadyProcessed(t *testing.T) {
+	sm := newTestStateMachine()
+	duplicate := &mockContextHandler{err: ErrAlreadyProcessed}
+	after := &mockContextHandler{}
+	registerChain(sm, SIMNotActivated, SIMActivated, FromContextHandler(duplicate), FromContextHandler(after))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	if !errors.Is(err, ErrAlreadyProcessed) {
+		t.Fatalf("Expected ErrAlreadyProcessed but got %v", err)
+	}
+	if after.handled != 0 {
+		t.Errorf("Handlers after a duplicate should not run")
+	}
+	if duplicate.rolledBack != 0 {
+		t.Errorf("A duplicate should not roll back any handler")
+	}
+	if so.State != SIMNotActivated {
+		t.Errorf("Expected state to stay %s but got %s", SIMNotActivated, so.State)
+	}
+}
+
+func TestTransitionToStop(t *testing.T) {
+	sm := newTestStateMachine()
+	stop := &mockContextHandler{err: ErrStop}
+	after := &mockContextHandler{}
+	registerChain(sm, SIMNotActivated, SIMActivated, FromContextHandler(stop), FromContextHandler(after))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("Expected the stopped transition to succeed but got %v", err)
+	}
+	if after.handled != 0 {
+		t.Errorf("Handlers after a stop should not run")
+	}
+	if so.State != SIMActivated {
+		t.Errorf("Expected state to be %s but got %s", SIMActivated, so.State)
+	}
+}
+
+func TestCheckProcessedHandlerContinuesForNewEvent(t *testing.T) {
+	sm := newTestStateMachine()
+	handler := AdaptHandler(&CheckProcessedHandler{stateMachine: sm})
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	so.EventID = "new_event"
+
+	err := handler.Handle(context.Background(), &TransitionContext{Machine: sm, Object: so, To: SIMActivated})
+	if OutcomeOf(err) != OutcomeContinue {
+		t.Fatalf("Expected a new event to continue the chain but got %v", err)
+	}
+}
+
+func TestCheckProcessedHandlerBoolPathContinuesForNewEvent(t *testing.T) {
+	sm := newTestStateMachine()
+	handler := &CheckProcessedHandler{stateMachine: sm}
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	so.EventID = "new_event"
+
+	if !handler.Handle(so, SIMActivated) {
+		t.Fatalf("Expected a new event to pass the bool dedupe check")
+	}
+}
+
+func TestTransitionToRecoversHandlerPanic(t *testing.T) {
+	sm := newTestStateMachine()
+	var alerted error
+	sm.SetAlertFunc(func(ctx context.Context, tc *TransitionContext, err error) {
+		alerted = err
+	})
+	first := &mockContextHandler{}
+	panicking := NewHandler("carrier", func(ctx context.Context, tc *TransitionContext) error {
+		panic("nil carrier client")
+	}, nil)
+	registerChain(sm, SIMNotActivated, SIMActivated, FromContextHandler(first), panicking)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected a *PanicError but got %v", err)
+	}
+	if panicErr.Handler != "carrier" || panicErr.Value != "nil carrier client" {
+		t.Errorf("Expected the panic of the carrier handler but got %v", panicErr)
+	}
+	if len(panicErr.Stack) == 0 {
+		t.Errorf("Expected the stack trace to be recorded")
+	}
+	if first.rolledBack != 1 {
+		t.Errorf("Expected the executed handler to be rolled back")
+	}
+	if alerted != panicErr {
+		t.Errorf("Expected the panic to be alerted but got %v", alerted)
+	}
+	if so.State != SIMNotActivated {
+		t.Errorf("Expected state to stay %s but got %s", SIMNotActivated, so.State)
+	}
+}
+
+func TestTransitionToRecoversRollbackPanic(t *testing.T) {
+	sm := newTestStateMachine()
+	panicking := NewHandler("carrier", func(ctx context.Context, tc *TransitionContext) error {
+		return nil
+	}, func(ctx context.Context, tc *TransitionContext) error {
+		panic("nil carrier client")
+	})
+	registerChain(sm, SIMNotActivated, SIMActivated, panicking, FromContextHandler(&mockContextHandler{err: errCarrierUnavailable}))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	if !errors.Is(err, ErrRollbackFailed) {
+		t.Fatalf("Expected the panicking rollback to fail the rollback but got %v", err)
+	}
+	if so.State != ManualReview {
+		t.Errorf("Expected state to be %s but got %s", ManualReview, so.State)
+	}
+}
+
+func TestParallelRecoversMemberPanic(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sibling := &countingContextHandler{}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, Parallel(
+		FromContextHandler(sibling),
+		NewHandler("cache", func(ctx context.Context, tc *TransitionContext) error {
+			panic("cache unavailable")
+		}, nil),
+	))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected a *PanicError but got %v", err)
+	}
+	if sibling.rolledBack != 1 {
+		t.Errorf("Expected the member that succeeded to be rolled back")
+	}
+}
@@ -1,5 +1,7 @@
 package statemachine
 
+import "context"
+
 type CheckEventIDHandler struct {
 	next Handler
 }
@@ -8,7 +10,11 @@ func (h *CheckEventIDHandler) Handle(u *StateObject, state string) bool {
 	if u.EventID == "" {
 		u.EventID = generateSignature(state)
 	}
-	return h.next.Handle(u, state)
+	return true
+}
+
+func (h *CheckEventIDHandler) Name() string {
+	return SlotEventID
 }
 
 func (h *CheckEventIDHandler) Rollback(u *StateObject, state string) bool {
@@ -29,11 +35,39 @@ type CheckProcessedHandler struct {
 	stateMachine *StateMachine
 }
 
+// Handle fails for an already processed event, so code walking Chain by hand
+// stops before the remaining handlers. The state machine runs the context
+// handler instead, which stops the transition with ErrAlreadyProcessed.
 func (h *CheckProcessedHandler) Handle(u *StateObject, state string) bool {
-	if h.stateMachine.isProcessed(u.EventID) {
-		return true
+	return !h.stateMachine.isProcessed(u.EventID)
+}
+
+// contextHandler reports already processed events as ErrAlreadyProcessed so
+// the transition stops without running the remaining handlers.
+func (h *CheckProcessedHandler) contextHandler() ContextHandler {
+	return checkProcessedContextHandler{handler: h}
+}
+
+type checkProcessedContextHandler struct {
+	handler *CheckProcessedHandler
+}
+
+func (h checkProcessedContextHandler) Handle(ctx context.Context, tc *TransitionContext) error {
+	if h.handler.stateMachine.isProcessed(tc.Object.EventID) {
+		return ErrAlreadyProcessed
+	}
+	return nil
+}
+
+func (h checkProcessedContextHandler) Rollback(ctx context.Context, tc *TransitionContext) error {
+	if !h.handler.Rollback(tc.Object, tc.To) {
+		return ErrRollbackFailed
 	}
-	return h.next.Handle(u, state)
+	return nil
+}
+
+func (h *CheckProcessedHandler) Name() string {
+	return SlotDedupe
 }
 
 func (h *CheckProcessedHandler) Rollback(u *StateObject, state string) bool {
@@ -50,6 +84,7 @@ func (h *CheckProcessedHandler) Next() Handler {
 }
 
 type MarkProcessedHandler struct {
+	next         Handler
 	stateMachine *StateMachine
 }
 
@@ -58,15 +93,21 @@ func (h *MarkProcessedHandler) Handle(u *StateObject, state string) bool {
 	return true
 }
 
+func (h *MarkProcessedHandler) Name() string {
+	return SlotMarkProcessed
+}
+
 func (h *MarkProcessedHandler) Rollback(u *StateObject, state string) bool {
 	// Define rollback logic, return false if cannot rollback
 	return true
 }
 
-func (h *MarkProcessedHandler) SetNext(handler Handler) {}
+func (h *MarkProcessedHandler) SetNext(handler Handler) {
+	h.next = handler
+}
 
 func (h *MarkProcessedHandler) Next() Handler {
-	return nil
+	return h.next
 }
 
 type TelemetryHandler struct {
@@ -78,10 +119,11 @@ func (h *TelemetryHandler) Handle(u *StateObject, state string) bool {
 	if h.telemetry {
 		// Fire telemetry logic here
 	}
-	if h.next != nil {
-		return h.next.Handle(u, state)
-	}
-	return false
+	return true
+}
+
+func (h *TelemetryHandler) Name() string {
+	return SlotTelemetry
 }
 
 func (h *TelemetryHandler) Rollback(u *StateObject, state string) bool {
@@ -106,10 +148,11 @@ func (h *AlertingHandler) Handle(u *StateObject, state string) bool {
 	if h.alerting {
 		// Fire alerting logic here
 	}
-	if h.next != nil {
-		return h.next.Handle(u, state)
-	}
-	return false
+	return true
+}
+
+func (h *AlertingHandler) Name() string {
+	return SlotAlerting
 }
 
 func (h *AlertingHandler) Rollback(u *StateObject, state string) bool {
@@ -132,7 +175,11 @@ type CustomCheckEventIDHandler struct {
 
 func (h *CustomCheckEventIDHandler) Handle(u *StateObject, state string) bool {
 	// Your custom logic here
-	return h.next.Handle(u, state)
+	return true
+}
+
+func (h *CustomCheckEventIDHandler) Name() string {
+	return SlotEventID
 }
 
 func (h *CustomCheckEventIDHandler) Rollback(u *StateObject, state string) bool {
@@ -155,7 +202,11 @@ type CustomCheckProcessedHandler struct {
 
 func (h *CustomCheckProcessedHandler) Handle(u *StateObject, state string) bool {
 	// Your custom logic here
-	return h.next.Handle(u, state)
+	return true
+}
+
+func (h *CustomCheckProcessedHandler) Name() string {
+	return SlotDedupe
 }
 
 func (h *CustomCheckProcessedHandler) Rollback(u *StateObject, state string) bool {
@@ -178,7 +229,11 @@ type CustomTelemetryHandler struct {
 
 func (h *CustomTelemetryHandler) Handle(u *StateObject, state string) bool {
 	// Your custom logic here
-	return h.next.Handle(u, state)
+	return true
+}
+
+func (h *CustomTelemetryHandler) Name() string {
+	return SlotTelemetry
 }
 
 func (h *CustomTelemetryHandler) Rollback(u *StateObject, state string) bool {
@@ -201,7 +256,11 @@ type CustomAlertingHandler struct {
 
 func (h *CustomAlertingHandler) Handle(u *StateObject, state string) bool {
 	// Your custom logic here
-	return h.next.Handle(u, state)
+	return true
+}
+
+func (h *CustomAlertingHandler) Name() string {
+	return SlotAlerting
 }
 
 func (h *CustomAlertingHandler) Rollback(u *StateObject, state string) bool {
@@ -224,7 +283,11 @@ type CustomMarkProcessedHandler struct {
 
 func (h *CustomMarkProcessedHandler) Handle(u *StateObject, state string) bool {
 	// Your custom logic here
-	return h.next.Handle(u, state)
+	return true
+}
+
+func (h *CustomMarkProcessedHandler) Name() string {
+	return SlotMarkProcessed
 }
 
 func (h *CustomMarkProcessedHandler) Rollback(u *StateObject, state string) bool {
@@ -0,0 +1,365 @@
+package statemachine
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// DefinitionSchema is the JSON Schema machine definitions are validated
+// against, published as definition.schema.json.
+//
+//go:embed definition.schema.json
+var DefinitionSchema string
+
+var definitionSchema = jsonschema.MustCompileString("definition.schema.json", DefinitionSchema)
+
+// Definition describes a machine declaratively, so it can be reviewed and
+// loaded from YAML or JSON instead of being registered in Go code.
+type Definition struct {
+	Name        string                 `json:"name,omitempty"`
+	Initial     string                 `json:"initial"`
+	States      []StateDefinition      `json:"states"`
+	Transitions []TransitionDefinition `json:"transitions"`
+}
+
+// StateDefinition declares a state.
+type StateDefinition struct {
+	Name  string `json:"name"`
+	Final bool   `json:"final,omitempty"`
+}
+
+// TransitionDefinition describes a transition. Guards and handlers are
+// referred to by the names they were registered under.
+type TransitionDefinition struct {
+	From     string   `json:"from,omitempty"`
+	Sources  []string `json:"sources,omitempty"`
+	Except   []string `json:"except,omitempty"`
+	To       string   `json:"to,omitempty"`
+	Event    string   `json:"event,omitempty"`
+	Internal bool     `json:"internal,omitempty"`
+	Guard    string   `json:"guard,omitempty"`
+	Handlers []string `json:"handlers,omitempty"`
+	Timeout  Duration `json:"timeout,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "30s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// DefinitionError lists the problems found in a machine definition.
+type DefinitionError struct {
+	Problems []string
+}
+
+func (e *DefinitionError) Error() string {
+	return "invalid machine definition: " + strings.Join(e.Problems, "; ")
+}
+
+// ParseDefinition parses a machine definition written in YAML or JSON and
+// validates it against DefinitionSchema.
+func ParseDefinition(data []byte) (*Definition, error) {
+	// YAML is a superset of JSON, so both are decoded the same way and
+	// converted to JSON for the schema.
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid machine definition: %w", err)
+	}
+	normalized, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid machine definition: %w", err)
+	}
+	if err := json.Unmarshal(normalized, &doc); err != nil {
+		return nil, err
+	}
+	if err := definitionSchema.Validate(doc); err != nil {
+		return nil, &DefinitionError{Problems: schemaProblems(err)}
+	}
+
+	var def Definition
+	if err := json.Unmarshal(normalized, &def); err != nil {
+		return nil, fmt.Errorf("invalid machine definition: %w", err)
+	}
+	return &def, nil
+}
+
+func schemaProblems(err error) []string {
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []string{err.Error()}
+	}
+	var problems []string
+	var collect func(*jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			location := e.InstanceLocation
+			if location == "" {
+				location = "/"
+			}
+			problems = append(problems, location+": "+e.Message)
+		}
+		for _, cause := range e.Causes {
+			collect(cause)
+		}
+	}
+	collect(validationErr)
+	return problems
+}
+
+// LoadDefinitionFile parses the machine definition in the file and loads it.
+func (sm *StateMachine) LoadDefinitionFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	def, err := ParseDefinition(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return sm.LoadDefinition(def)
+}
+
+// LoadDefinition declares the states of the definition and registers its
+// transitions. Guards and handlers must be registered before. Nothing is
+// registered when the definition refers to undeclared states or to unknown
+// guards or handlers.
+func (sm *StateMachine) LoadDefinition(def *Definition) error {
+	if problems := sm.checkDefinition(def); len(problems) > 0 {
+		return &DefinitionError{Problems: problems}
+	}
+
+	for _, state := range def.States {
+		if state.Final {
+			sm.DeclareFinalState(state.Name)
+		} else {
+			sm.DeclareState(state.Name)
+		}
+	}
+	sm.SetInitialState(def.Initial)
+
+	for _, t := range def.Transitions {
+		handlers := make([]Handler, 0, len(t.Handlers))
+		for _, name := range t.Handlers {
+			handlers = append(handlers, sm.namedHandlers[name])
+		}
+
+		var transition *StateTransition
+		switch {
+		case t.Internal:
+			transition = sm.RegisterInternalTransition(t.From, t.Event, handlers...)
+		case len(t.Sources) > 0:
+			transition = sm.RegisterTransitionFromMany(t.Sources, t.To, handlers...)
+		case t.Event != "":
+			transition = sm.RegisterEventTransition(t.From, t.Event, t.To, handlers...)
+			transition.Except = append([]string(nil), t.Except...)
+		case t.From == AnyState:
+			transition = sm.RegisterTransitionFromAnyExcept(t.Except, t.To, handlers...)
+		default:
+			transition = sm.RegisterTransition(t.From, t.To, handlers...)
+		}
+
+		if t.Guard != "" {
+			transition.WithGuard(t.Guard, sm.guards[t.Guard])
+		}
+		if t.Timeout > 0 {
+			transition.WithTimeout(time.Duration(t.Timeout))
+		}
+	}
+	return nil
+}
+
+// checkDefinition reports what the schema can't check: that states are
+// declared once and referenced states, guards and handlers exist.
+func (sm *StateMachine) checkDefinition(def *Definition) []string {
+	var problems []string
+	declared := make(map[string]bool, len(def.States))
+	for _, state := range def.States {
+		if declared[state.Name] {
+			problems = append(problems, fmt.Sprintf("state %s is declared more than once", state.Name))
+		}
+		declared[state.Name] = true
+	}
+
+	checkState := func(where, state string) {
+		if state != "" && state != AnyState && !declared[state] {
+			problems = append(problems, fmt.Sprintf("%s: undeclared state %s", where, state))
+		}
+	}
+	checkState("initial", def.Initial)
+
+	for i, t := range def.Transitions {
+		where := fmt.Sprintf("transitions[%d]", i)
+		checkState(where+".from", t.From)
+		for _, state := range t.Sources {
+			checkState(where+".sources", state)
+		}
+		for _, state := range t.Except {
+			checkState(where+".except", state)
+		}
+		checkState(where+".to", t.To)
+		if t.Guard != "" && sm.guards[t.Guard] == nil {
+			problems = append(problems, fmt.Sprintf("%s.guard: unknown guard %s", where, t.Guard))
+		}
+		for _, name := range t.Handlers {
+			if sm.namedHandlers[name] == nil {
+				problems = append(problems, fmt.Sprintf("%s.handlers: unknown handler %s", where, name))
+			}
+		}
+	}
+	return problems
+}
+
+// RegisterHandler registers a handler under a name, so machine definitions
+// can refer to it. Every transition naming it shares the instance, so their
+// handlers are listed by Handlers; Chain is only valid for the transition
+// loaded last.
+func (sm *StateMachine) RegisterHandler(name string, handler Handler) {
+	if sm.namedHandlers == nil {
+		sm.namedHandlers = make(map[string]Handler)
+	}
+	sm.namedHandlers[name] = handler
+}
+
+// DeclareState declares a state of the machine. Transitions don't need
+// their states declared, but declared states are what Validate checks the
+// transitions against.
+func (sm *StateMachine) DeclareState(state string) {
+	if sm.states == nil {
+		sm.states = make(map[string]bool)
+	}
+	if _, ok := sm.states[state]; !ok {
+		sm.states[state] = false
+	}
+}
+
+// DeclareFinalState declares a state objects are not expected to leave.
+func (sm *StateMachine) DeclareFinalState(state string) {
+	sm.DeclareState(state)
+	sm.states[state] = true
+}
+
+// SetInitialState sets the state new objects start in.
+func (sm *StateMachine) SetInitialState(state string) {
+	sm.DeclareState(state)
+	sm.initial = state
+}
+
+// initialState returns the state new objects start in, SIMNotActivated
+// unless another one was set.
+func (sm *StateMachine) initialState() string {
+	if sm == nil || sm.initial == "" {
+		return SIMNotActivated
+	}
+	return sm.initial
+}
+
+// Definition describes the registered states and transitions, so the
+// machine can be exported. Handlers and guards are listed by the names they
+// were registered under; those registered without a name are left out.
+// Transitions are ordered by source state and event; those sharing both keep
+// the order Fire tries them in.
+func (sm *StateMachine) Definition() *Definition {
+	def := &Definition{}
+	states := sm.graph().states
+	if initial := sm.initialState(); containsState(states, initial) {
+		def.Initial = initial
+	}
+	for _, state := range states {
+		def.States = append(def.States, StateDefinition{Name: state, Final: sm.states[state]})
+	}
+
+	seen := make(map[*StateTransition]bool)
+	add := func(t *StateTransition, td TransitionDefinition) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		td.Event = t.Event
+		td.Guard = t.GuardName
+		td.Timeout = Duration(t.Timeout)
+		td.Handlers = sm.handlerNames(t)
+		def.Transitions = append(def.Transitions, td)
+	}
+	// Event transitions come first, in the order they were registered,
+	// which is the order Fire tries them in. Those sharing a pair are only
+	// registered in events.
+	for _, t := range sm.eventTransitions() {
+		td := TransitionDefinition{From: t.From, To: t.To}
+		if t.From == AnyState {
+			td.Except = append([]string(nil), t.Except...)
+		}
+		add(t, td)
+	}
+	for _, t := range sm.transitions {
+		add(t, TransitionDefinition{From: t.From, To: t.To})
+	}
+	for _, t := range sm.sourceSets {
+		add(t, TransitionDefinition{Sources: append([]string(nil), t.Sources...), To: t.To})
+	}
+	for _, t := range sm.wildcards {
+		add(t, TransitionDefinition{From: AnyState, Except: append([]string(nil), t.Except...), To: t.To})
+	}
+	for _, t := range sm.internal {
+		add(t, TransitionDefinition{From: t.From, Internal: true})
+	}
+
+	// Transitions with the same source and event keep their registration
+	// order, since it decides which of them runs
+	sort.SliceStable(def.Transitions, func(i, j int) bool {
+		a, b := def.Transitions[i], def.Transitions[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.Event != b.Event {
+			return a.Event < b.Event
+		}
+		return a.Event == "" && a.To < b.To
+	})
+	return def
+}
+
+// handlerNames returns the names the handlers of the transition were
+// registered under with RegisterHandler.
+func (sm *StateMachine) handlerNames(t *StateTransition) []string {
+	var names []string
+	for _, handler := range t.handlerList() {
+		for name, registered := range sm.namedHandlers {
+			if sameHandler(registered, handler) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// sameHandler reports whether both are the same handler, without panicking
+// on handler types that can't be compared.
+func sameHandler(a, b Handler) bool {
+	typ := reflect.TypeOf(a)
+	return typ == reflect.TypeOf(b) && typ.Comparable() && a == b
+}
@@ -0,0 +1,249 @@
+package statemachine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+const simDefinition = `
+name: sim
+initial: SIMNotActivated
+states:
+  - name: SIMNotActivated
+  - name: SIMActivated
+  - name: SIMRetired
+    final: true
+  - name: ManualReview
+transitions:
+  - from: SIMNotActivated
+    to: SIMActivated
+    event: activate
+    guard: hasCarrier
+    handlers: [carrierCheck]
+    timeout: 30s
+  - from: SIMActivated
+    event: refresh
+    internal: true
+  - sources: [SIMNotActivated, SIMActivated]
+    to: SIMRetired
+  - from: "*"
+    except: [SIMRetired]
+    to: ManualReview
+`
+
+func newDefinitionStateMachine(handler Handler) *StateMachine {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.RegisterGuard("hasCarrier", hasCarrier)
+	sm.RegisterHandler("carrierCheck", handler)
+	return sm
+}
+
+func TestLoadDefinition(t *testing.T) {
+	def, err := ParseDefinition([]byte(simDefinition))
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+	if def.Transitions[0].Timeout != Duration(30*time.Second) {
+		t.Errorf("Expected a 30s timeout but got %v", time.Duration(def.Transitions[0].Timeout))
+	}
+
+	handler := &mockContextHandler{}
+	sm := newDefinitionStateMachine(FromContextHandler(handler))
+	if err := sm.LoadDefinition(def); err != nil {
+		t.Fatalf("LoadDefinition failed: %v", err)
+	}
+	if !sm.states[SIMRetired] || sm.states[SIMActivated] {
+		t.Errorf("Expected only %s to be final", SIMRetired)
+	}
+
+	so := NewStateObject(map[string]interface{}{"carrier": "TelecomProvider"}, sm, zaptest.NewLogger(t))
+	if so.State != SIMNotActivated {
+		t.Errorf("Expected new objects to start in the initial state but got %s", so.State)
+	}
+	if err := so.Fire(sm, "activate"); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	if so.State != SIMActivated || handler.handled != 1 {
+		t.Errorf("Expected the activate event to run the carrierCheck handler")
+	}
+	if err := so.TransitionInternal(sm, "refresh"); err != nil {
+		t.Errorf("Expected the internal transition to be registered: %v", err)
+	}
+	if err := so.TransitionTo(sm, ManualReview); err != nil {
+		t.Errorf("Expected the wildcard transition to be registered: %v", err)
+	}
+
+	transition, _ := sm.lookupTransition(SIMNotActivated, SIMActivated)
+	if transition.GuardName != "hasCarrier" || transition.Timeout != 30*time.Second {
+		t.Errorf("Expected the guard and timeout to be set on the transition")
+	}
+}
+
+func TestParseDefinitionJSON(t *testing.T) {
+	def, err := ParseDefinition([]byte(`{
+		"initial": "SIMNotActivated",
+		"states": [{"name": "SIMNotActivated"}, {"name": "SIMActivated"}],
+		"transitions": [{"from": "SIMNotActivated", "to": "SIMActivated"}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+	if len(def.States) != 2 || def.Transitions[0].To != SIMActivated {
+		t.Errorf("Expected the JSON definition to be decoded but got %+v", def)
+	}
+}
+
+func TestParseDefinitionRejectsSchemaViolations(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":       "initial: A\nstates: [{name: A}]\ntransitions: []\nowner: me\n",
+		"missing initial":     "states: [{name: A}]\ntransitions: []\n",
+		"missing to":          "initial: A\nstates: [{name: A}]\ntransitions: [{from: A}]\n",
+		"internal with to":    "initial: A\nstates: [{name: A}]\ntransitions: [{from: A, to: A, event: e, internal: true}]\n",
+		"except without star": "initial: A\nstates: [{name: A}]\ntransitions: [{from: A, to: A, except: [A]}]\n",
+		"bad timeout":         "initial: A\nstates: [{name: A}]\ntransitions: [{from: A, to: A, timeout: soon}]\n",
+	}
+	for name, definition := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseDefinition([]byte(definition))
+			var defErr *DefinitionError
+			if !errors.As(err, &defErr) {
+				t.Errorf("Expected a *DefinitionError but got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadDefinitionReportsUnknownReferences(t *testing.T) {
+	def, err := ParseDefinition([]byte(`
+initial: SIMNotActivated
+states:
+  - name: SIMNotActivated
+transitions:
+  - from: SIMNotActivated
+    to: SIMActivated
+    guard: isPrepaid
+    handlers: [billingCheck]
+`))
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+
+	sm := newDefinitionStateMachine(FromContextHandler(&mockContextHandler{}))
+	err = sm.LoadDefinition(def)
+	var defErr *DefinitionError
+	if !errors.As(err, &defErr) || len(defErr.Problems) != 3 {
+		t.Fatalf("Expected three problems but got %v", err)
+	}
+	for _, want := range []string{"undeclared state SIMActivated", "unknown guard isPrepaid", "unknown handler billingCheck"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+	if _, exists := sm.lookupTransition(SIMNotActivated, SIMActivated); exists {
+		t.Errorf("Expected nothing to be registered from an invalid definition")
+	}
+}
+
+func TestLoadDefinitionFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sim.yaml")
+	if err := os.WriteFile(path, []byte(simDefinition), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sm := newDefinitionStateMachine(FromContextHandler(&mockContextHandler{}))
+	if err := sm.LoadDefinitionFile(path); err != nil {
+		t.Fatalf("LoadDefinitionFile failed: %v", err)
+	}
+	if sm.initial != SIMNotActivated {
+		t.Errorf("Expected the initial state to be set")
+	}
+}
+
+func TestDefinitionKeepsEventsSharingAPair(t *testing.T) {
+	def, err := ParseDefinition([]byte(`
+initial: SIMNotActivated
+states:
+  - name: SIMNotActivated
+  - name: SIMActivated
+transitions:
+  - from: SIMNotActivated
+    to: SIMActivated
+    event: activate
+  - from: SIMNotActivated
+    to: SIMActivated
+    event: reactivate
+`))
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+	sm := newDefinitionStateMachine(FromContextHandler(&mockContextHandler{}))
+	if err := sm.LoadDefinition(def); err != nil {
+		t.Fatalf("LoadDefinition failed: %v", err)
+	}
+
+	var events []string
+	for _, td := range sm.Definition().Transitions {
+		events = append(events, td.Event)
+	}
+	if strings.Join(events, ",") != "activate,reactivate" {
+		t.Errorf("Expected both events in the definition but got %v", events)
+	}
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.Fire(sm, "reactivate"); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	transition, _ := sm.lookupTransition(SIMNotActivated, SIMActivated)
+	if transition.Event != "activate" {
+		t.Errorf("Expected TransitionTo to run the first transition of the pair but got %s", transition.Event)
+	}
+}
+
+func TestLoadDefinitionSharesNamedHandlers(t *testing.T) {
+	def, err := ParseDefinition([]byte(`
+initial: SIMNotActivated
+states:
+  - name: SIMNotActivated
+  - name: SIMActivated
+  - name: SIMRetired
+transitions:
+  - from: SIMNotActivated
+    to: SIMActivated
+    handlers: [carrierCheck, notify]
+  - from: SIMActivated
+    to: SIMRetired
+    handlers: [carrierCheck]
+`))
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+	handler := &mockContextHandler{}
+	notify := &mockContextHandler{}
+	sm := newDefinitionStateMachine(FromContextHandler(handler))
+	sm.RegisterHandler("notify", FromContextHandler(notify))
+	if err := sm.LoadDefinition(def); err != nil {
+		t.Fatalf("LoadDefinition failed: %v", err)
+	}
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("TransitionTo failed: %v", err)
+	}
+	if err := so.TransitionTo(sm, SIMRetired); err != nil {
+		t.Fatalf("TransitionTo failed: %v", err)
+	}
+	if handler.handled != 2 || notify.handled != 1 {
+		t.Errorf("Expected each transition to run its own handlers but got %d and %d calls", handler.handled, notify.handled)
+	}
+	for _, td := range sm.Definition().Transitions {
+		if td.To == SIMActivated && strings.Join(td.Handlers, ",") != "carrierCheck,notify" {
+			t.Errorf("Expected the handlers of the first transition to be kept but got %v", td.Handlers)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package statemachine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DiagramOptions configures the diagrams rendered by DOT, Mermaid and
+// PlantUML.
+type DiagramOptions struct {
+	// Current is highlighted as the state of an object.
+	Current string
+	// History is the path of states the object went through, oldest
+	// first. Its states, and in DOT and PlantUML its edges, are highlighted.
+	History []string
+	// HideHandlers leaves the handler chains out of the edge labels.
+	HideHandlers bool
+}
+
+// diagramEdge is a transition drawn between two states.
+type diagramEdge struct {
+	from       string
+	to         string
+	transition *StateTransition
+}
+
+// diagramEdges returns the edges of the registered transitions, sorted, so
+// the same machine always renders the same diagram. Wildcards are drawn from
+// every state they apply to and internal transitions as loops.
+func (sm *StateMachine) diagramEdges() []diagramEdge {
+	g := sm.graph()
+	var edges []diagramEdge
+	seen := make(map[*StateTransition]bool)
+	for _, t := range sm.transitions {
+		seen[t] = true
+		edges = append(edges, diagramEdge{from: t.From, to: t.To, transition: t})
+	}
+	for _, t := range sm.sourceSets {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		for _, source := range t.Sources {
+			edges = append(edges, diagramEdge{from: source, to: t.To, transition: t})
+		}
+	}
+	for _, t := range sm.wildcards {
+		seen[t] = true
+		for _, state := range g.states {
+			if state == t.To || containsState(t.Except, state) {
+				continue
+			}
+			if _, exact := sm.transitions[state+"->"+t.To]; exact {
+				continue
+			}
+			if _, set := sm.sourceSets[state+"->"+t.To]; set {
+				continue
+			}
+			edges = append(edges, diagramEdge{from: state, to: t.To, transition: t})
+		}
+	}
+	for _, t := range sm.internal {
+		if t.From == AnyState {
+			for _, state := range g.states {
+				if _, own := sm.internal[state+"#"+t.Event]; own {
+					continue
+				}
+				edges = append(edges, diagramEdge{from: state, to: state, transition: t})
+			}
+			continue
+		}
+		edges = append(edges, diagramEdge{from: t.From, to: t.From, transition: t})
+	}
+	// Event transitions sharing a pair with another transition are only
+	// registered in events
+	for _, t := range sm.eventTransitions() {
+		if seen[t] {
+			continue
+		}
+		if t.From != AnyState {
+			edges = append(edges, diagramEdge{from: t.From, to: t.To, transition: t})
+			continue
+		}
+		for _, state := range g.states {
+			if state != t.To && !containsState(t.Except, state) {
+				edges = append(edges, diagramEdge{from: state, to: t.To, transition: t})
+			}
+		}
+	}
+
+	// Edges that only differ by guard are sorted by its name. Event
+	// transitions sharing a guard as well keep their registration order.
+	sort.SliceStable(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.from != b.from {
+			return a.from < b.from
+		}
+		if a.to != b.to {
+			return a.to < b.to
+		}
+		if a.transition.Event != b.transition.Event {
+			return a.transition.Event < b.transition.Event
+		}
+		return a.transition.GuardName < b.transition.GuardName
+	})
+	return edges
+}
+
+// edgeLabel describes a transition as "event [guard]" followed, unless
+// hidden, by the names of its handlers in order.
+func edgeLabel(t *StateTransition, opts DiagramOptions) []string {
+	var lines []string
+	var trigger []string
+	if t.Event != "" {
+		trigger = append(trigger, t.Event)
+	}
+	if t.Kind == InternalTransition {
+		trigger = append(trigger, "(internal)")
+	}
+	if t.GuardName != "" {
+		trigger = append(trigger, "["+t.GuardName+"]")
+	} else if t.Guard != nil {
+		trigger = append(trigger, "[guard]")
+	}
+	if len(trigger) > 0 {
+		lines = append(lines, strings.Join(trigger, " "))
+	}
+	if !opts.HideHandlers {
+		var names []string
+		for _, handler := range t.handlerList() {
+			names = append(names, handlerName(handler))
+		}
+		if len(names) > 0 {
+			lines = append(lines, strings.Join(names, ", "))
+		}
+	}
+	return lines
+}
+
+// historyEdges returns the from->to pairs taken along the history.
+func historyEdges(history []string) map[string]bool {
+	taken := make(map[string]bool)
+	for i := 1; i < len(history); i++ {
+		taken[history[i-1]+"->"+history[i]] = true
+	}
+	return taken
+}
+
+// DOT renders the transition graph as a Graphviz DOT digraph.
+func (sm *StateMachine) DOT(opts DiagramOptions) string {
+	visited := make(map[string]bool)
+	for _, state := range opts.History {
+		visited[state] = true
+	}
+	taken := historyEdges(opts.History)
+
+	var b strings.Builder
+	b.WriteString("digraph statemachine {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, style=rounded];\n")
+
+	states := sm.graph().states
+	if initial := sm.initialState(); containsState(states, initial) {
+		b.WriteString("\t\"__initial\" [shape=point];\n")
+		fmt.Fprintf(&b, "\t\"__initial\" -> %s;\n", dotQuote(initial))
+	}
+	for _, state := range states {
+		var attrs []string
+		if sm.states[state] {
+			attrs = append(attrs, "peripheries=2")
+		}
+		switch {
+		case state == opts.Current:
+			attrs = append(attrs, `style="rounded,filled,bold"`, `fillcolor="#ffcc80"`)
+		case visited[state]:
+			attrs = append(attrs, `style="rounded,filled"`, `fillcolor="#e0e0e0"`)
+		}
+		if len(attrs) == 0 {
+			fmt.Fprintf(&b, "\t%s;\n", dotQuote(state))
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", dotQuote(state), strings.Join(attrs, ", "))
+	}
+
+	for _, edge := range sm.diagramEdges() {
+		var attrs []string
+		if label := edgeLabel(edge.transition, opts); len(label) > 0 {
+			attrs = append(attrs, "label="+dotQuote(strings.Join(label, "\n")))
+		}
+		if edge.transition.Kind == InternalTransition {
+			attrs = append(attrs, "style=dashed")
+		}
+		if taken[edge.from+"->"+edge.to] {
+			attrs = append(attrs, `color="#e65100"`, "penwidth=2")
+		}
+		line := fmt.Sprintf("\t%s -> %s", dotQuote(edge.from), dotQuote(edge.to))
+		if len(attrs) > 0 {
+			line += " [" + strings.Join(attrs, ", ") + "]"
+		}
+		b.WriteString(line + ";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+var diagramUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// diagramID turns a state name into a Mermaid or PlantUML identifier.
+func diagramID(state string) string {
+	return diagramUnsafe.ReplaceAllString(state, "_")
+}
+
+// Mermaid renders the transition graph as a Mermaid state diagram. Mermaid
+// can't style the edges of a state diagram, so the history only highlights
+// states.
+func (sm *StateMachine) Mermaid(opts DiagramOptions) string {
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+
+	states := sm.graph().states
+	for _, state := range states {
+		if id := diagramID(state); id != state {
+			fmt.Fprintf(&b, "    state %q as %s\n", state, id)
+		}
+	}
+	if initial := sm.initialState(); containsState(states, initial) {
+		fmt.Fprintf(&b, "    [*] --> %s\n", diagramID(initial))
+	}
+	for _, edge := range sm.diagramEdges() {
+		line := fmt.Sprintf("    %s --> %s", diagramID(edge.from), diagramID(edge.to))
+		if label := edgeLabel(edge.transition, opts); len(label) > 0 {
+			line += ": " + strings.ReplaceAll(strings.Join(label, "<br/>"), ":", "#58;")
+		}
+		b.WriteString(line + "\n")
+	}
+	for _, state := range states {
+		if sm.states[state] {
+			fmt.Fprintf(&b, "    %s --> [*]\n", diagramID(state))
+		}
+	}
+
+	var visited []string
+	for _, state := range opts.History {
+		if state != opts.Current && !containsState(visited, state) {
+			visited = append(visited, state)
+		}
+	}
+	if len(visited) > 0 {
+		b.WriteString("    classDef visited fill:#e0e0e0\n")
+		for _, state := range visited {
+			fmt.Fprintf(&b, "    class %s visited\n", diagramID(state))
+		}
+	}
+	if opts.Current != "" {
+		b.WriteString("    classDef current fill:#ffcc80,stroke-width:2px\n")
+		fmt.Fprintf(&b, "    class %s current\n", diagramID(opts.Current))
+	}
+	return b.String()
+}
+
+// PlantUML renders the transition graph as a PlantUML state diagram.
+func (sm *StateMachine) PlantUML(opts DiagramOptions) string {
+	visited := make(map[string]bool)
+	for _, state := range opts.History {
+		visited[state] = true
+	}
+	taken := historyEdges(opts.History)
+
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+
+	states := sm.graph().states
+	for _, state := range states {
+		var color string
+		switch {
+		case state == opts.Current:
+			color = " #FFCC80"
+		case visited[state]:
+			color = " #E0E0E0"
+		}
+		if id := diagramID(state); id != state {
+			fmt.Fprintf(&b, "state %q as %s%s\n", state, id, color)
+		} else if color != "" {
+			fmt.Fprintf(&b, "state %s%s\n", state, color)
+		}
+	}
+	if initial := sm.initialState(); containsState(states, initial) {
+		fmt.Fprintf(&b, "[*] --> %s\n", diagramID(initial))
+	}
+	for _, edge := range sm.diagramEdges() {
+		arrow := "-->"
+		if taken[edge.from+"->"+edge.to] {
+			arrow = "-[#E65100,bold]->"
+		} else if edge.transition.Kind == InternalTransition {
+			arrow = "-[dashed]->"
+		}
+		line := fmt.Sprintf("%s %s %s", diagramID(edge.from), arrow, diagramID(edge.to))
+		if label := edgeLabel(edge.transition, opts); len(label) > 0 {
+			line += " : " + strings.Join(label, `\n`)
+		}
+		b.WriteString(line + "\n")
+	}
+	for _, state := range states {
+		if sm.states[state] {
+			fmt.Fprintf(&b, "%s --> [*]\n", diagramID(state))
+		}
+	}
+	b.WriteString("@enduml\n")
+	return b.String()
+}
@@ -0,0 +1,145 @@
+package statemachine
+
+import (
+	"strings"
+	"testing"
+)
+
+func newDiagramStateMachine() *StateMachine {
+	sm := newLifecycleStateMachine()
+	sm.RegisterInternalTransition(SIMActivated, "refresh")
+	sm.RegisterHandler("carrierCheck", NewHandler("carrierCheck", nil, nil))
+	sm.RegisterTransition(SIMActivated, SIMRetired, NewHandler("releaseNumber", nil, nil))
+	return sm
+}
+
+func TestDOT(t *testing.T) {
+	sm := newDiagramStateMachine()
+	dot := sm.DOT(DiagramOptions{
+		Current: SIMActivated,
+		History: []string{SIMNotActivated, SIMActivated},
+	})
+
+	for _, want := range []string{
+		"digraph statemachine {",
+		`"__initial" -> "SIMNotActivated";`,
+		`"SIMRetired" [peripheries=2];`,
+		`"SIMActivated" [style="rounded,filled,bold", fillcolor="#ffcc80"];`,
+		`"SIMNotActivated" [style="rounded,filled", fillcolor="#e0e0e0"];`,
+		`"SIMNotActivated" -> "SIMActivated" [label="activate [hasCarrier]", color="#e65100", penwidth=2];`,
+		`"SIMActivated" -> "SIMActivated" [label="refresh (internal)", style=dashed];`,
+		`"SIMActivated" -> "SIMRetired" [label="releaseNumber"];`,
+		`"SIMActivated" -> "ManualReview";`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("Expected %s in\n%s", want, dot)
+		}
+	}
+	if strings.Contains(dot, `"SIMRetired" -> "ManualReview"`) {
+		t.Errorf("Expected the excluded state to have no wildcard edge")
+	}
+}
+
+func TestDOTShowsDefaultHandlers(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.RegisterTransition(SIMNotActivated, SIMActivated)
+
+	dot := sm.DOT(DiagramOptions{})
+	if !strings.Contains(dot, `label="eventid, dedupe, telemetry, alerting, markprocessed"`) {
+		t.Errorf("Expected the default handler chain in the label but got\n%s", dot)
+	}
+	if strings.Contains(sm.DOT(DiagramOptions{HideHandlers: true}), "label=") {
+		t.Errorf("Expected no labels when handlers are hidden")
+	}
+}
+
+func TestDOTShowsEveryEventTransition(t *testing.T) {
+	sm := newLifecycleStateMachine()
+	sm.RegisterEventTransition(SIMNotActivated, "reactivate", SIMActivated)
+	sm.RegisterEventTransition(SIMActivated, "retire", SIMRetired).WithGuard("hasCarrier", hasCarrier)
+	sm.RegisterEventTransition(AnyState, "escalate", ManualReview)
+
+	dot := sm.DOT(DiagramOptions{})
+	for _, want := range []string{
+		`"SIMNotActivated" -> "SIMActivated" [label="activate [hasCarrier]"];`,
+		`"SIMNotActivated" -> "SIMActivated" [label="reactivate"];`,
+		`"SIMActivated" -> "SIMRetired";`,
+		`"SIMActivated" -> "SIMRetired" [label="retire [hasCarrier]"];`,
+		`"SIMRetired" -> "ManualReview" [label="escalate"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("Expected %s in\n%s", want, dot)
+		}
+	}
+}
+
+func TestDOTIsDeterministic(t *testing.T) {
+	sm := newLifecycleStateMachine()
+	for _, guard := range []string{"vip", "any", "prepaid", "business"} {
+		sm.RegisterEventTransition(SIMActivated, "review", ManualReview).WithGuard(guard, hasCarrier)
+	}
+	sm.RegisterInternalTransition(AnyState, "refresh")
+	sm.RegisterInternalTransition(SIMActivated, "refresh")
+
+	dot := sm.DOT(DiagramOptions{})
+	for i := 0; i < 20; i++ {
+		if again := sm.DOT(DiagramOptions{}); again != dot {
+			t.Fatalf("Expected the same diagram on every render but got\n%s\nthen\n%s", dot, again)
+		}
+	}
+	any := strings.Index(dot, `label="review [any]"`)
+	vip := strings.Index(dot, `label="review [vip]"`)
+	if any < 0 || vip < any {
+		t.Errorf("Expected guarded edges sorted by guard name in\n%s", dot)
+	}
+	if strings.Count(dot, `"SIMActivated" -> "SIMActivated"`) != 1 {
+		t.Errorf("Expected the internal transition of the state to replace the wildcard one in\n%s", dot)
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	sm := newDiagramStateMachine()
+	sm.RegisterTransition(SIMRetired, "Number Ported")
+	mermaid := sm.Mermaid(DiagramOptions{
+		Current: SIMActivated,
+		History: []string{SIMNotActivated, SIMActivated},
+	})
+
+	for _, want := range []string{
+		"stateDiagram-v2\n",
+		`    state "Number Ported" as Number_Ported`,
+		"    [*] --> SIMNotActivated\n",
+		"    SIMNotActivated --> SIMActivated: activate [hasCarrier]\n",
+		"    SIMRetired --> Number_Ported\n",
+		"    SIMRetired --> [*]\n",
+		"    class SIMNotActivated visited\n",
+		"    class SIMActivated current\n",
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Expected %q in\n%s", want, mermaid)
+		}
+	}
+}
+
+func TestPlantUML(t *testing.T) {
+	sm := newDiagramStateMachine()
+	plantUML := sm.PlantUML(DiagramOptions{
+		Current: SIMActivated,
+		History: []string{SIMNotActivated, SIMActivated},
+	})
+
+	for _, want := range []string{
+		"@startuml\n",
+		"state SIMActivated #FFCC80\n",
+		"state SIMNotActivated #E0E0E0\n",
+		"[*] --> SIMNotActivated\n",
+		"SIMNotActivated -[#E65100,bold]-> SIMActivated : activate [hasCarrier]\n",
+		"SIMActivated -[dashed]-> SIMActivated : refresh (internal)\n",
+		"SIMRetired --> [*]\n",
+		"@enduml\n",
+	} {
+		if !strings.Contains(plantUML, want) {
+			t.Errorf("Expected %q in\n%s", want, plantUML)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package sim is an example of typed states and events generated from a
+// machine definition with statemachine-gen.
+package sim
+
+//go:generate go run github.com/hibrid/statemachine/cmd/statemachine-gen -in sim.yaml -out sim_states.go
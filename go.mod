@@ -1,16 +1,25 @@
 module github.com/hibrid/statemachine
 
-go 1.17
+go 1.18
 
 require (
+	capnproto.org/go/capnp/v3 v3.0.0-alpha.24
+	github.com/fxamacker/cbor/v2 v2.7.0
 	github.com/go-redis/redis/v8 v8.11.5
+	github.com/santhosh-tekuri/jsonschema/v5 v5.2.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	go.uber.org/zap v1.21.0
+	google.golang.org/protobuf v1.34.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/benbjohnson/clock v1.1.0 // indirect
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
 )
@@ -0,0 +1,50 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrGuardRejected is matched by the error of a transition whose guard did
+// not allow it.
+var ErrGuardRejected = errors.New("guard rejected the transition")
+
+// GuardError is returned when the guard of a transition does not allow it.
+type GuardError struct {
+	Guard string
+	From  string
+	To    string
+	Event string
+}
+
+func (e *GuardError) Error() string {
+	guard := "guard"
+	if e.Guard != "" {
+		guard = "guard " + e.Guard
+	}
+	if e.Event != "" {
+		return fmt.Sprintf("%s rejected event %s from %s to %s", guard, e.Event, e.From, e.To)
+	}
+	return fmt.Sprintf("%s rejected the transition from %s to %s", guard, e.From, e.To)
+}
+
+func (e *GuardError) Is(target error) bool {
+	return target == ErrGuardRejected
+}
+
+// WithGuard makes the transition run only when the guard allows it. The
+// name identifies the guard in errors and exported diagrams.
+func (t *StateTransition) WithGuard(name string, guard Guard) *StateTransition {
+	t.GuardName = name
+	t.Guard = guard
+	return t
+}
+
+// RegisterGuard registers a guard under a name, so machine definitions can
+// refer to it.
+func (sm *StateMachine) RegisterGuard(name string, guard Guard) {
+	if sm.guards == nil {
+		sm.guards = make(map[string]Guard)
+	}
+	sm.guards[name] = guard
+}
@@ -0,0 +1,109 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func hasCarrier(tc *TransitionContext) bool {
+	_, ok := tc.Object.Data["carrier"]
+	return ok
+}
+
+func TestGuardRejectsTransition(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	handler := &mockContextHandler{}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, FromContextHandler(handler)).WithGuard("hasCarrier", hasCarrier)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	if !errors.Is(err, ErrGuardRejected) {
+		t.Fatalf("Expected ErrGuardRejected but got %v", err)
+	}
+	if handler.handled != 0 || so.State != SIMNotActivated {
+		t.Errorf("Expected a rejected transition to run no handler and keep the state")
+	}
+
+	so.Data["carrier"] = "TelecomProvider"
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("Expected the guard to allow the transition but got %v", err)
+	}
+}
+
+func TestFireChoosesTransitionByGuard(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.RegisterEventTransition(SIMNotActivated, "activate", SIMActivated).WithGuard("hasCarrier", hasCarrier)
+	sm.RegisterEventTransition(SIMNotActivated, "activate", ManualReview)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.Fire(sm, "activate"); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	if so.State != ManualReview {
+		t.Errorf("Expected the unguarded transition to %s but got %s", ManualReview, so.State)
+	}
+
+	so = NewStateObject(map[string]interface{}{"carrier": "TelecomProvider"}, sm, zaptest.NewLogger(t))
+	if err := so.Fire(sm, "activate"); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	if so.State != SIMActivated {
+		t.Errorf("Expected the guarded transition to %s but got %s", SIMActivated, so.State)
+	}
+}
+
+func TestFireUnknownEvent(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.RegisterEventTransition(SIMNotActivated, "activate", SIMActivated).WithGuard("hasCarrier", hasCarrier)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.Fire(sm, "suspend"); err == nil {
+		t.Errorf("Expected an unknown event to fail")
+	}
+	var guardErr *GuardError
+	if err := so.Fire(sm, "activate"); !errors.As(err, &guardErr) || guardErr.Event != "activate" {
+		t.Errorf("Expected a *GuardError for the event but got %v", err)
+	}
+}
+
+func TestTransitionTimeout(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	slow := NewHandler("", func(ctx context.Context, tc *TransitionContext) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	}, nil)
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, slow).WithTimeout(10 * time.Millisecond)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the chain to time out but got %v", err)
+	}
+}
+
+func TestFireInternalEvent(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	handler := &mockContextHandler{}
+	sm.RegisterInternalTransition(SIMActivated, "refresh", FromContextHandler(handler))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	so.State = SIMActivated
+	if err := so.Fire(sm, "refresh"); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	if handler.handled != 1 || so.State != SIMActivated {
+		t.Errorf("Expected the internal transition to run and keep the state")
+	}
+}
@@ -0,0 +1,150 @@
+package statemachine
+
+import "fmt"
+
+// Names of the slots occupied by the default handlers.
+const (
+	SlotEventID       = "eventid"
+	SlotDedupe        = "dedupe"
+	SlotTelemetry     = "telemetry"
+	SlotAlerting      = "alerting"
+	SlotMarkProcessed = "markprocessed"
+)
+
+type handlerEditOp int
+
+const (
+	replaceHandler handlerEditOp = iota
+	insertHandlerBefore
+	insertHandlerAfter
+	removeHandler
+)
+
+// handlerEdit is a change to the handler list relative to a named slot.
+type handlerEdit struct {
+	op      handlerEditOp
+	slot    string
+	handler Handler
+}
+
+// apply returns the handler list with the edit applied. Edits for a slot
+// that is not in the list leave it unchanged.
+func (e handlerEdit) apply(handlers []Handler) []Handler {
+	i := indexOfSlot(handlers, e.slot)
+	if i < 0 {
+		return handlers
+	}
+	switch e.op {
+	case replaceHandler:
+		handlers[i] = e.handler
+	case insertHandlerBefore:
+		handlers = insertHandler(handlers, i, e.handler)
+	case insertHandlerAfter:
+		handlers = insertHandler(handlers, i+1, e.handler)
+	case removeHandler:
+		handlers = append(handlers[:i], handlers[i+1:]...)
+	}
+	return handlers
+}
+
+// ReplaceHandler replaces the handler in the given slot for every transition
+// registered afterwards.
+func (sm *StateMachine) ReplaceHandler(slot string, handler Handler) {
+	sm.handlerEdits = append(sm.handlerEdits, handlerEdit{op: replaceHandler, slot: slot, handler: handler})
+}
+
+// InsertHandlerBefore runs the handler right before the given slot for every
+// transition registered afterwards.
+func (sm *StateMachine) InsertHandlerBefore(slot string, handler Handler) {
+	sm.handlerEdits = append(sm.handlerEdits, handlerEdit{op: insertHandlerBefore, slot: slot, handler: handler})
+}
+
+// InsertHandlerAfter runs the handler right after the given slot for every
+// transition registered afterwards.
+func (sm *StateMachine) InsertHandlerAfter(slot string, handler Handler) {
+	sm.handlerEdits = append(sm.handlerEdits, handlerEdit{op: insertHandlerAfter, slot: slot, handler: handler})
+}
+
+// RemoveHandler drops the handler in the given slot from every transition
+// registered afterwards.
+func (sm *StateMachine) RemoveHandler(slot string) {
+	sm.handlerEdits = append(sm.handlerEdits, handlerEdit{op: removeHandler, slot: slot})
+}
+
+// Replace replaces the handler in the given slot of this transition.
+func (t *StateTransition) Replace(slot string, handler Handler) *StateTransition {
+	return t.edit(handlerEdit{op: replaceHandler, slot: slot, handler: handler})
+}
+
+// InsertBefore runs the handler right before the given slot of this
+// transition.
+func (t *StateTransition) InsertBefore(slot string, handler Handler) *StateTransition {
+	return t.edit(handlerEdit{op: insertHandlerBefore, slot: slot, handler: handler})
+}
+
+// InsertAfter runs the handler right after the given slot of this
+// transition.
+func (t *StateTransition) InsertAfter(slot string, handler Handler) *StateTransition {
+	return t.edit(handlerEdit{op: insertHandlerAfter, slot: slot, handler: handler})
+}
+
+// Remove drops the handler in the given slot from this transition.
+func (t *StateTransition) Remove(slot string) *StateTransition {
+	return t.edit(handlerEdit{op: removeHandler, slot: slot})
+}
+
+func (t *StateTransition) edit(e handlerEdit) *StateTransition {
+	t.setHandlers(e.apply(t.handlerList()))
+	return t
+}
+
+// setHandlers replaces the handler list and links the handlers through
+// SetNext so Chain and Next keep working. Linking a handler shared with
+// other transitions overwrites its successor in their chains; their handler
+// lists are not affected.
+func (t *StateTransition) setHandlers(handlers []Handler) {
+	t.Handlers = handlers
+	t.Chain = nil
+	if len(handlers) > 0 {
+		t.Chain = CreateHandlerChain(handlers...)
+		handlers[len(handlers)-1].SetNext(nil)
+	}
+}
+
+// handlerSlot returns the slot name a handler declares through Named.
+func handlerSlot(h Handler) string {
+	if named, ok := h.(Named); ok {
+		return named.Name()
+	}
+	return ""
+}
+
+// handlerName returns the name used for a handler in logs and errors.
+func handlerName(h Handler) string {
+	if slot := handlerSlot(h); slot != "" {
+		return slot
+	}
+	if adapter, ok := h.(*contextHandlerAdapter); ok {
+		return fmt.Sprintf("%T", adapter.handler)
+	}
+	return fmt.Sprintf("%T", h)
+}
+
+func indexOfSlot(handlers []Handler, slot string) int {
+	if slot == "" {
+		return -1
+	}
+	for i, handler := range handlers {
+		if handlerSlot(handler) == slot {
+			return i
+		}
+	}
+	return -1
+}
+
+func insertHandler(handlers []Handler, i int, handler Handler) []Handler {
+	handlers = append(handlers, nil)
+	copy(handlers[i+1:], handlers[i:])
+	handlers[i] = handler
+	return handlers
+}
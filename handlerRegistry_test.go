@@ -0,0 +1,117 @@
+package statemachine
+
+import (
+	"reflect"
+	"testing"
+)
+
+type namedHandler struct {
+	MockHandler
+	name string
+}
+
+func (h *namedHandler) Name() string {
+	return h.name
+}
+
+func handlerNames(handlers []Handler) []string {
+	var names []string
+	for _, handler := range handlers {
+		names = append(names, handlerName(handler))
+	}
+	return names
+}
+
+func TestNamedHandlerReplacesDefaultSlot(t *testing.T) {
+	sm := newTestStateMachine()
+	telemetry := &namedHandler{name: SlotTelemetry}
+	transition := sm.RegisterTransition(SIMNotActivated, SIMActivated, telemetry)
+
+	if transition.Handlers[2] != telemetry {
+		t.Fatalf("Expected named handler to replace the telemetry slot")
+	}
+	if len(transition.Handlers) != 5 {
+		t.Errorf("Expected 5 handlers but got %v", handlerNames(transition.Handlers))
+	}
+}
+
+func TestGlobalHandlerEdits(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.RemoveHandler(SlotAlerting)
+	sm.InsertHandlerBefore(SlotEventID, &namedHandler{name: "tracing"})
+	sm.InsertHandlerAfter(SlotDedupe, &namedHandler{name: "audit"})
+	sm.ReplaceHandler(SlotTelemetry, &namedHandler{name: "metrics"})
+
+	transition := sm.RegisterTransition(SIMNotActivated, SIMActivated, &MockHandler{})
+	expected := []string{"tracing", SlotEventID, SlotDedupe, "audit", "metrics", "*statemachine.MockHandler", SlotMarkProcessed}
+	if names := handlerNames(transition.Handlers); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected handlers %v but got %v", expected, names)
+	}
+}
+
+func TestTransitionHandlerEdits(t *testing.T) {
+	sm := newTestStateMachine()
+	other := sm.RegisterTransition(SIMActivated, SIMDeactivated)
+	transition := sm.RegisterTransition(SIMNotActivated, SIMActivated).
+		Remove(SlotTelemetry).
+		InsertAfter(SlotMarkProcessed, &namedHandler{name: "notify"}).
+		Replace(SlotAlerting, &namedHandler{name: "pager"})
+
+	expected := []string{SlotEventID, SlotDedupe, "pager", SlotMarkProcessed, "notify"}
+	if names := handlerNames(transition.Handlers); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected handlers %v but got %v", expected, names)
+	}
+	if len(other.Handlers) != 5 {
+		t.Errorf("Edits of one transition should not affect another")
+	}
+
+	i := 0
+	for handler := transition.Chain; handler != nil; handler = handler.Next() {
+		if handler != transition.Handlers[i] {
+			t.Fatalf("Expected chain link %d to match the handler list", i)
+		}
+		i++
+	}
+	if i != len(transition.Handlers) {
+		t.Errorf("Expected chain to have %d links but got %d", len(transition.Handlers), i)
+	}
+}
+
+func TestHandlerEditUnknownSlot(t *testing.T) {
+	sm := newTestStateMachine()
+	transition := sm.RegisterTransition(SIMNotActivated, SIMActivated).Remove("unknown")
+	if len(transition.Handlers) != 5 {
+		t.Errorf("Editing an unknown slot should leave the handlers unchanged")
+	}
+}
+
+func TestSharedHandlerKeepsHandlerLists(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	shared := &countingHandler{}
+	activate := &countingHandler{}
+	deactivate := &countingHandler{}
+	activation := sm.RegisterTransition(SIMNotActivated, SIMActivated, shared, activate)
+	deactivation := sm.RegisterTransition(SIMActivated, SIMDeactivated, shared, deactivate)
+
+	so := NewStateObject(map[string]interface{}{}, sm, nil)
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("TransitionTo failed: %v", err)
+	}
+	if shared.calls != 1 || activate.calls != 1 || deactivate.calls != 0 {
+		t.Errorf("Expected the first transition to run its own handlers but got %d, %d and %d calls",
+			shared.calls, activate.calls, deactivate.calls)
+	}
+	if activation.Handlers[1] != activate {
+		t.Errorf("Expected the handler list of the first transition to be kept")
+	}
+
+	// Only the chain of the transition registered last can be walked
+	var chain []Handler
+	for handler := deactivation.Chain; handler != nil; handler = handler.Next() {
+		chain = append(chain, handler)
+	}
+	if !reflect.DeepEqual(chain, deactivation.Handlers) {
+		t.Errorf("Expected the chain of the last transition to match its handler list")
+	}
+}
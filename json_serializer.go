@@ -6,6 +6,11 @@ import (
 
 type JSONSerialization struct{}
 
+// ContentType returns ContentTypeJSON.
+func (j JSONSerialization) ContentType() string {
+	return ContentTypeJSON
+}
+
 func (j JSONSerialization) Serialize(so *StateObject) ([]byte, error) {
 	return json.Marshal(so)
 }
@@ -15,3 +20,9 @@ func (j JSONSerialization) Deserialize(data []byte) (*StateObject, error) {
 	err := json.Unmarshal(data, &so)
 	return &so, err
 }
+
+// DeserializeInto decodes into an existing StateObject, so the data of a
+// TypedStateObject is decoded straight into its type.
+func (j JSONSerialization) DeserializeInto(data []byte, so *StateObject) error {
+	return json.Unmarshal(data, so)
+}
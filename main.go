@@ -9,9 +9,11 @@ import (
 	"fmt"
 	"log"
 	"runtime"
+	"sort"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
 )
 
 const (
@@ -24,6 +26,22 @@ const (
 	ManualReview        = "ManualReview"
 )
 
+// TransitionKind describes how a transition treats the state of the object.
+type TransitionKind int
+
+const (
+	// ExternalTransition leaves the source state and enters the target state.
+	ExternalTransition TransitionKind = iota
+	// SelfTransition leaves and re-enters the same state.
+	SelfTransition
+	// InternalTransition runs its handlers without leaving the state.
+	InternalTransition
+)
+
+// AnyState is the wildcard source state used to register a transition that
+// applies from every state.
+const AnyState = "*"
+
 var defaultConfig = HandlerConfig{
 	CheckEventID:   true,
 	CheckProcessed: true,
@@ -37,6 +55,12 @@ func (sm *StateMachine) SetHandlerConfig(config HandlerConfig) {
 	sm.config = config
 }
 
+// SetSerializer changes the serializer of the machine and keeps the rest of
+// its HandlerConfig, unlike SetHandlerConfig.
+func (sm *StateMachine) SetSerializer(serializer Serialization) {
+	sm.config.Serializer = serializer
+}
+
 // Initialize Redis client
 func InitializeRedis(addr string) *redis.Client {
 	rdb := redis.NewClient(&redis.Options{
@@ -49,7 +73,10 @@ func InitializeRedis(addr string) *redis.Client {
 
 func NewStateMachine(redisAddr string) *StateMachine {
 	return &StateMachine{
-		transitions: make(map[string]StateTransition),
+		transitions: make(map[string]*StateTransition),
+		sourceSets:  make(map[string]*StateTransition),
+		wildcards:   make(map[string]*StateTransition),
+		internal:    make(map[string]*StateTransition),
 		config:      defaultConfig,
 		redisClient: InitializeRedis(redisAddr),
 	}
@@ -73,6 +100,38 @@ func (sm *StateMachine) LogErr(err error) {
 	}
 }
 
+// SetAlertFunc sets the function called to alert on failures that need
+// attention, such as a handler panic.
+func (sm *StateMachine) SetAlertFunc(alert AlertFunc) {
+	sm.alert = alert
+}
+
+// reportPanic logs a recovered handler panic with its stack trace and
+// alerts on it. Other errors are ignored.
+func (sm *StateMachine) reportPanic(ctx context.Context, tc *TransitionContext, err error) {
+	var panicErr *PanicError
+	if sm == nil || !errors.As(err, &panicErr) {
+		return
+	}
+	sm.LogErr(panicErr)
+	if sm.DebugLogging {
+		sm.Log(string(panicErr.Stack))
+	}
+	if tc.Object.Logger != nil {
+		tc.Object.Logger.Error("handler_panic",
+			zap.String("handler", panicErr.Handler),
+			zap.String("event_id", tc.Object.EventID),
+			zap.String("from_state", tc.From),
+			zap.String("to_state", tc.To),
+			zap.Any("panic", panicErr.Value),
+			zap.ByteString("stack", panicErr.Stack),
+		)
+	}
+	if sm.alert != nil {
+		sm.alert(ctx, tc, panicErr)
+	}
+}
+
 func (sm *StateMachine) GetDefaultHandlers() []Handler {
 	var defaultHandlers []Handler
 	if sm.config.CheckEventID {
@@ -126,83 +185,252 @@ func (sm *StateMachine) SetDB(db *sql.DB) {
 	sm.db = db
 }
 
+// EmitEvent publishes a StateObject, encoded with the configured serializer,
+// or an already encoded []byte through the event emitter.
 func (sm *StateMachine) EmitEvent(event interface{}) {
 	if sm.eventEmitter == nil {
 		log.Println("Warning: No event emitter set. Unable to emit event.")
 		return
 	}
-	// Emit event using the appropriate connection based on the event type or configuration
-	// This is a placeholder. Implement the actual event emitting logic here.
-}
-
-func getHandlerType(h Handler) string {
-	switch h.(type) {
-	case *CheckEventIDHandler, *CustomCheckEventIDHandler:
-		return "CheckEventIDHandler"
-	case *CheckProcessedHandler, *CustomCheckProcessedHandler:
-		return "CheckProcessedHandler"
-	case *TelemetryHandler, *CustomTelemetryHandler:
-		return "TelemetryHandler"
-	case *AlertingHandler, *CustomAlertingHandler:
-		return "AlertingHandler"
-	case *MarkProcessedHandler, *CustomMarkProcessedHandler:
-		return "MarkProcessedHandler"
-	// ... other cases can be added as needed ...
+	publisher, ok := sm.eventEmitter.(EventPublisher)
+	if !ok {
+		log.Printf("Warning: Event emitter %T can't publish events.", sm.eventEmitter)
+		return
+	}
+
+	var contentType string
+	var payload []byte
+	switch e := event.(type) {
+	case *StateObject:
+		serializer := sm.serializer()
+		data, err := serializer.Serialize(e)
+		if err != nil {
+			sm.LogErr(fmt.Errorf("Serializing event failed: %w", err))
+			return
+		}
+		contentType, payload = contentTypeOf(serializer), data
+	case []byte:
+		contentType, payload = "application/octet-stream", e
 	default:
-		return ""
+		log.Printf("Warning: Unable to emit event of type %T.", event)
+		return
+	}
+	if err := publisher.Publish(contentType, payload); err != nil {
+		sm.LogErr(fmt.Errorf("Emitting event failed: %w", err))
 	}
 }
 
-func (sm *StateMachine) RegisterTransition(from, to string, customHandlers ...Handler) {
+// buildHandlers assembles the default handlers, the global handler edits
+// and the custom handlers into the ordered list the engine runs for a
+// transition. A custom handler whose name matches a slot already in the list
+// replaces it; the others run right before the markprocessed slot.
+func (sm *StateMachine) buildHandlers(customHandlers []Handler) []Handler {
 	allHandlers := sm.GetDefaultHandlers()
+	if sm.config.MarkProcessed {
+		markProcessedHandler := &MarkProcessedHandler{
+			stateMachine: sm,
+		}
+		allHandlers = append(allHandlers, markProcessedHandler)
+	}
 
-	// Map to track custom handlers
-	customHandlerMap := make(map[string]Handler)
-	handlersToRemove := []int{}
-	for i, handler := range customHandlers {
-		handlerType := getHandlerType(handler)
-		if handlerType != "" {
-			customHandlerMap[handlerType] = handler
-			handlersToRemove = append(handlersToRemove, i)
+	for _, edit := range sm.handlerEdits {
+		allHandlers = edit.apply(allHandlers)
+	}
+
+	for _, handler := range customHandlers {
+		slot := handlerSlot(handler)
+		if i := indexOfSlot(allHandlers, slot); slot != "" && i >= 0 {
+			allHandlers[i] = handler
+			continue
 		}
+		if i := indexOfSlot(allHandlers, SlotMarkProcessed); i >= 0 {
+			allHandlers = insertHandler(allHandlers, i, handler)
+			continue
+		}
+		allHandlers = append(allHandlers, handler)
 	}
 
-	// Remove the custom handlers that were added to the map from the customHandlers slice
-	for i := len(handlersToRemove) - 1; i >= 0; i-- {
-		index := handlersToRemove[i]
-		customHandlers = append(customHandlers[:index], customHandlers[index+1:]...)
+	return allHandlers
+}
+
+// newStateTransition creates a transition running the given handlers.
+func newStateTransition(from, to string, handlers []Handler) *StateTransition {
+	transition := &StateTransition{
+		From: from,
+		To:   to,
+	}
+	transition.setHandlers(handlers)
+	return transition
+}
+
+// RegisterTransition registers the transition from one state to another.
+// Passing AnyState as from registers a wildcard transition that applies to
+// every source state other than to itself. The returned transition can be
+// used to edit its handler slots.
+func (sm *StateMachine) RegisterTransition(from, to string, customHandlers ...Handler) *StateTransition {
+	if from == AnyState {
+		return sm.RegisterTransitionFromAnyExcept(nil, to, customHandlers...)
 	}
 
-	// Replace default handlers with custom ones where provided
-	for i, defaultHandler := range allHandlers {
-		handlerType := getHandlerType(defaultHandler)
-		if customHandler, ok := customHandlerMap[handlerType]; ok {
-			allHandlers[i] = customHandler
+	transition := sm.newTransition(from, to, customHandlers)
+	sm.transitions[from+"->"+to] = transition
+	return transition
+}
+
+// newTransition builds the transition from one state to another without
+// registering it.
+func (sm *StateMachine) newTransition(from, to string, customHandlers []Handler) *StateTransition {
+	kind := ExternalTransition
+	if from == to {
+		kind = SelfTransition
+	}
+	transition := newStateTransition(from, to, sm.buildHandlers(customHandlers))
+	transition.Kind = kind
+	return transition
+}
+
+// RegisterSelfTransition registers a transition from a state to itself. The
+// object exits and re-enters the state, so exit and entry hooks run.
+func (sm *StateMachine) RegisterSelfTransition(state string, customHandlers ...Handler) *StateTransition {
+	return sm.RegisterTransition(state, state, customHandlers...)
+}
+
+// RegisterInternalTransition registers a transition that is triggered by an
+// event while the object is in the given state. Its handlers run and may
+// update Data, but the object never leaves the state. Passing AnyState
+// makes the internal transition available in every state.
+func (sm *StateMachine) RegisterInternalTransition(state, event string, customHandlers ...Handler) *StateTransition {
+	transition := newStateTransition(state, state, sm.buildHandlers(customHandlers))
+	transition.Kind = InternalTransition
+	transition.Event = event
+	sm.internal[state+"#"+event] = transition
+	return transition
+}
+
+// RegisterEventTransition registers the transition from one state to another
+// that Fire runs for the given event. Several transitions may share a source
+// state and event when guards choose between them; they are tried in the
+// order they were registered. Passing AnyState as from makes the event
+// available in every state other than to. The transition can also be run
+// with TransitionTo, unless another transition was registered for the same
+// pair first; events sharing a pair are only told apart by Fire.
+func (sm *StateMachine) RegisterEventTransition(from, event, to string, customHandlers ...Handler) *StateTransition {
+	transition := sm.newTransition(from, to, customHandlers)
+	transition.Event = event
+	if from == AnyState {
+		if _, ok := sm.wildcards[to]; !ok {
+			sm.wildcards[to] = transition
 		}
+	} else if _, ok := sm.transitions[from+"->"+to]; !ok {
+		sm.transitions[from+"->"+to] = transition
 	}
+	if sm.events == nil {
+		sm.events = make(map[string][]*StateTransition)
+	}
+	sm.events[from+"#"+event] = append(sm.events[from+"#"+event], transition)
+	return transition
+}
 
-	// Confirm that all handlers are chained
-	for i := 0; i < len(allHandlers)-1; i++ {
-		allHandlers[i].SetNext(allHandlers[i+1])
+// RegisterTransitionFromMany registers a single transition, sharing one
+// handler chain, from each of the given source states to the target state.
+// A transition registered for the exact from->to pair takes precedence. The
+// target state is skipped when it is one of the sources, like a wildcard
+// never applies to its target; use RegisterSelfTransition for that.
+func (sm *StateMachine) RegisterTransitionFromMany(from []string, to string, customHandlers ...Handler) *StateTransition {
+	transition := newStateTransition(AnyState, to, sm.buildHandlers(customHandlers))
+	for _, source := range from {
+		if source == to {
+			continue
+		}
+		transition.Sources = append(transition.Sources, source)
+		sm.sourceSets[source+"->"+to] = transition
 	}
+	return transition
+}
 
-	lastHandler := allHandlers[len(allHandlers)-1]
-	for _, handler := range customHandlers {
-		lastHandler.SetNext(handler)
-		lastHandler = handler
+// RegisterTransitionFromAnyExcept registers a wildcard transition to the
+// target state from every state except the excluded ones and the target
+// itself. Exact pairs and source sets take precedence over the wildcard.
+func (sm *StateMachine) RegisterTransitionFromAnyExcept(except []string, to string, customHandlers ...Handler) *StateTransition {
+	transition := newStateTransition(AnyState, to, sm.buildHandlers(customHandlers))
+	transition.Except = append([]string(nil), except...)
+	sm.wildcards[to] = transition
+	return transition
+}
+
+// lookupTransition resolves the transition for the given pair. An exact
+// from->to registration wins over a source set, which wins over a wildcard.
+func (sm *StateMachine) lookupTransition(from, to string) (*StateTransition, bool) {
+	if transition, ok := sm.transitions[from+"->"+to]; ok {
+		return transition, true
+	}
+	if transition, ok := sm.sourceSets[from+"->"+to]; ok {
+		return transition, true
+	}
+	if transition, ok := sm.wildcards[to]; ok && from != to && !containsState(transition.Except, from) {
+		return transition, true
 	}
+	return nil, false
+}
 
-	if sm.config.MarkProcessed {
-		markProcessedHandler := &MarkProcessedHandler{
-			stateMachine: sm,
+// lookupInternalTransition resolves the internal transition for an event in
+// the given state, falling back to one registered for AnyState.
+func (sm *StateMachine) lookupInternalTransition(state, event string) (*StateTransition, bool) {
+	if transition, ok := sm.internal[state+"#"+event]; ok {
+		return transition, true
+	}
+	transition, ok := sm.internal[AnyState+"#"+event]
+	return transition, ok
+}
+
+// lookupEventTransitions returns the transitions registered for an event in
+// the given state, followed by those registered for AnyState.
+func (sm *StateMachine) lookupEventTransitions(state, event string) []*StateTransition {
+	transitions := append([]*StateTransition(nil), sm.events[state+"#"+event]...)
+	for _, transition := range sm.events[AnyState+"#"+event] {
+		if transition.To != state && !containsState(transition.Except, state) {
+			transitions = append(transitions, transition)
 		}
-		lastHandler.SetNext(markProcessedHandler)
 	}
+	return transitions
+}
+
+// eventTransitions returns every registered event transition, ordered by
+// source state and event, then in the order they were registered.
+func (sm *StateMachine) eventTransitions() []*StateTransition {
+	keys := make([]string, 0, len(sm.events))
+	for key := range sm.events {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var transitions []*StateTransition
+	for _, key := range keys {
+		transitions = append(transitions, sm.events[key]...)
+	}
+	return transitions
+}
+
+// OnEnter registers a hook that runs whenever an object enters the state,
+// including when it re-enters it through a self transition.
+func (sm *StateMachine) OnEnter(state string, hook func(*StateObject)) {
+	if sm.onEnter == nil {
+		sm.onEnter = make(map[string][]func(*StateObject))
+	}
+	sm.onEnter[state] = append(sm.onEnter[state], hook)
+}
+
+// OnExit registers a hook that runs whenever an object leaves the state,
+// including when it leaves it through a self transition.
+func (sm *StateMachine) OnExit(state string, hook func(*StateObject)) {
+	if sm.onExit == nil {
+		sm.onExit = make(map[string][]func(*StateObject))
+	}
+	sm.onExit[state] = append(sm.onExit[state], hook)
+}
 
-	sm.transitions[from+"->"+to] = StateTransition{
-		From:  from,
-		To:    to,
-		Chain: allHandlers[0],
+func (sm *StateMachine) runHooks(hooks []func(*StateObject), so *StateObject) {
+	for _, hook := range hooks {
+		hook(so)
 	}
 }
 
@@ -213,41 +441,285 @@ func generateSignature(eventContent string) string {
 }
 
 func (so *StateObject) TransitionTo(sm *StateMachine, state string) error {
+	return so.TransitionToContext(context.Background(), sm, state)
+}
+
+// TransitionToContext transitions the object to the given state. The context
+// is passed to every ContextHandler in the chain and the chain stops once it
+// is done. A failing handler is reported as a *HandlerError. An event that was
+// already processed is reported as ErrAlreadyProcessed and the state is kept.
+func (so *StateObject) TransitionToContext(ctx context.Context, sm *StateMachine, state string) error {
 	sm.Log("Starting transition from", so.State, "to", state)
 
-	transition, exists := sm.transitions[so.State+"->"+state]
+	transition, exists := sm.lookupTransition(so.State, state)
 	if !exists {
 		return errors.New("invalid transition from " + so.State + " to " + state)
 	}
 
-	handler := transition.Chain
-	var executedHandlers []Handler
-	for handler != nil {
-		success := handler.Handle(so, state)
-		if !success {
-			// Rollback
-			// Log failure in the handler chain
-			sm.LogErr(fmt.Errorf("Handler %s failed for eventID %s", getHandlerType(handler), so.EventID))
-			for i := len(executedHandlers) - 1; i >= 0; i-- {
-				if !executedHandlers[i].Rollback(so, state) {
-					// Log failure in the handler chain
-					sm.LogErr(fmt.Errorf("Handler %s failed to rollback for eventID %s", getHandlerType(handler), so.EventID))
-					so.State = ManualReview
-					return errors.New("failed to rollback, moving to manual review")
-				}
+	tc := &TransitionContext{
+		Machine: sm,
+		Object:  so,
+		From:    so.State,
+		To:      state,
+		Event:   transition.Event,
+		Kind:    transition.Kind,
+		Log:     &StateTransitionLog{FromState: so.State, ToState: state, Timestamp: nowFunc()},
+	}
+	if transition.Guard != nil && !transition.Guard(tc) {
+		return &GuardError{Guard: transition.GuardName, From: so.State, To: state}
+	}
+	return so.conclude(ctx, sm, transition, tc)
+}
+
+// Fire runs the transition registered for the event in the current state.
+// When several are registered, the first one whose guard allows it runs.
+// An event with only an internal transition runs it like
+// TransitionInternal.
+func (so *StateObject) Fire(sm *StateMachine, event string) error {
+	return so.FireContext(context.Background(), sm, event)
+}
+
+// FireContext is Fire with a context that is passed to every ContextHandler
+// in the chain.
+func (so *StateObject) FireContext(ctx context.Context, sm *StateMachine, event string) error {
+	sm.Log("Firing", event, "in", so.State)
+
+	transitions := sm.lookupEventTransitions(so.State, event)
+	if len(transitions) == 0 {
+		if _, internal := sm.lookupInternalTransition(so.State, event); internal {
+			return so.TransitionInternalContext(ctx, sm, event)
+		}
+		return errors.New("invalid event " + event + " in " + so.State)
+	}
+
+	var rejected error
+	for _, transition := range transitions {
+		tc := &TransitionContext{
+			Machine: sm,
+			Object:  so,
+			From:    so.State,
+			To:      transition.To,
+			Event:   event,
+			Kind:    transition.Kind,
+			Log:     &StateTransitionLog{FromState: so.State, ToState: transition.To, Timestamp: nowFunc()},
+		}
+		if transition.Guard != nil && !transition.Guard(tc) {
+			if rejected == nil {
+				rejected = &GuardError{Guard: transition.GuardName, From: so.State, To: transition.To, Event: event}
 			}
-			return errors.New("transition failed")
+			continue
 		}
-		executedHandlers = append(executedHandlers, handler)
-		handler = handler.Next()
+		return so.conclude(ctx, sm, transition, tc)
+	}
+	return rejected
+}
+
+// conclude runs the handler chain of the transition and, when it succeeds,
+// moves the object to the target state.
+func (so *StateObject) conclude(ctx context.Context, sm *StateMachine, transition *StateTransition, tc *TransitionContext) error {
+	state := tc.To
+	if err := so.runChain(ctx, sm, transition, tc); err != nil {
+		return err
 	}
 
 	// Log the conclusion of the transition
 	sm.Log("Successfully concluded transition from", so.State, "to", state)
+	from := so.State
+	sm.runHooks(sm.onExit[from], so)
 	so.State = state
+	sm.runHooks(sm.onEnter[state], so)
+	tc.Log.EventID = so.EventID
+	so.logTransition(*tc.Log, sm)
 	return nil
 }
 
+// TransitionInternal runs the internal transition registered for the given
+// event in the current state. The handler chain runs exactly like it does for
+// TransitionTo, but the state is kept and no exit or entry hooks fire.
+func (so *StateObject) TransitionInternal(sm *StateMachine, event string) error {
+	return so.TransitionInternalContext(context.Background(), sm, event)
+}
+
+// TransitionInternalContext is TransitionInternal with a context that is
+// passed to every ContextHandler in the chain.
+func (so *StateObject) TransitionInternalContext(ctx context.Context, sm *StateMachine, event string) error {
+	sm.Log("Starting internal transition", event, "in", so.State)
+
+	transition, exists := sm.lookupInternalTransition(so.State, event)
+	if !exists {
+		return errors.New("invalid internal transition " + event + " in " + so.State)
+	}
+
+	tc := &TransitionContext{
+		Machine: sm,
+		Object:  so,
+		From:    so.State,
+		To:      so.State,
+		Event:   event,
+		Kind:    InternalTransition,
+		Log:     &StateTransitionLog{FromState: so.State, ToState: so.State, Timestamp: nowFunc()},
+	}
+	if transition.Guard != nil && !transition.Guard(tc) {
+		return &GuardError{Guard: transition.GuardName, From: so.State, To: so.State, Event: event}
+	}
+	if err := so.runChain(ctx, sm, transition, tc); err != nil {
+		return err
+	}
+	tc.Log.EventID = so.EventID
+	so.logTransition(*tc.Log, sm)
+
+	sm.Log("Successfully concluded internal transition", event, "in", so.State)
+	return nil
+}
+
+// runChain executes the handlers of a transition in order and rolls back the
+// executed handlers when one of them fails, restoring Data to a snapshot
+// taken before the first handler ran. Each handler runs exactly once;
+// handlers never invoke their successor themselves. A handler that stops the
+// chain ends it successfully, except for ErrAlreadyProcessed which is
+// returned so the caller leaves the object untouched.
+func (so *StateObject) runChain(ctx context.Context, sm *StateMachine, transition *StateTransition, tc *TransitionContext) error {
+	_, err := so.executeChain(ctx, sm, transition, tc, transition.handlerList())
+	return err
+}
+
+// executeChain runs the given handlers of a transition like runChain and
+// returns the handlers that executed, so a caller that commits the result
+// later can still roll them back.
+func (so *StateObject) executeChain(ctx context.Context, sm *StateMachine, transition *StateTransition, tc *TransitionContext, handlers []Handler) ([]executedHandler, error) {
+	tc.transition = transition
+	var restore func()
+	if !transition.SkipDataSnapshot {
+		restore = so.snapshotData()
+	}
+
+	if transition.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, transition.Timeout)
+		defer cancel()
+	}
+
+	var executed []executedHandler
+	for _, handler := range handlers {
+		contextHandler := transition.wrap(sm, AdaptHandler(handler))
+		attemptsBefore := len(tc.Log.Attempts)
+		err := ctx.Err()
+		if err == nil {
+			err = callSafely(handlerName(handler), func() error {
+				return tc.handle(ctx, handler, contextHandler)
+			})
+			sm.reportPanic(ctx, tc, err)
+		}
+		if OutcomeOf(err) == OutcomeStop {
+			sm.Log("Handler", handlerName(handler), "stopped the chain for eventID", so.EventID+":", err)
+			if errors.Is(err, ErrAlreadyProcessed) {
+				return executed, ErrAlreadyProcessed
+			}
+			return executed, nil
+		}
+		if err != nil {
+			// Log failure in the handler chain
+			sm.LogErr(fmt.Errorf("Handler %s failed for eventID %s: %w", handlerName(handler), so.EventID, err))
+			handlerErr := &HandlerError{
+				Handler:  handlerName(handler),
+				EventID:  so.EventID,
+				Err:      err,
+				Attempts: len(tc.Log.Attempts) - attemptsBefore,
+			}
+
+			// A handler that compensates its own partial work is rolled back
+			// first. One that reports it could not undo that work counts as
+			// a failed rollback.
+			var failed *executedHandler
+			if compensatesOnFailure(handler) {
+				failed = &executedHandler{handler: handler, contextHandler: contextHandler}
+			}
+			var rollbackErrs []error
+			if errors.Is(err, ErrRollbackFailed) {
+				rollbackErrs = append(rollbackErrs, fmt.Errorf("handler %s: %w", handlerName(handler), err))
+			}
+			handlerErr.Rollback = so.rollback(ctx, sm, transition.RollbackPolicy, tc, failed, executed, rollbackErrs)
+			if restore != nil {
+				restore()
+			}
+			if handlerErr.Rollback != nil && transition.RollbackPolicy == RollbackEscalate {
+				handlerErr.ManualReview = true
+				so.State = ManualReview
+			}
+			// Log the failed transition, so the attempts of retried
+			// handlers are kept
+			tc.Log.EventID = so.EventID
+			tc.Log.Err = handlerErr
+			so.logTransition(*tc.Log, sm)
+			return nil, handlerErr
+		}
+		executed = append(executed, executedHandler{handler: handler, contextHandler: contextHandler})
+	}
+	return executed, nil
+}
+
+type executedHandler struct {
+	handler        Handler
+	contextHandler ContextHandler
+}
+
+// rollback rolls back the failed handler, when it compensates its own work,
+// and then the executed handlers in reverse order, following the rollback
+// policy when a rollback fails. It returns the rollback failures.
+func (so *StateObject) rollback(ctx context.Context, sm *StateMachine, policy RollbackPolicy, tc *TransitionContext, failed *executedHandler, executed []executedHandler, rollbackErrs []error) error {
+	if len(rollbackErrs) > 0 && policy != RollbackBestEffort {
+		return rollbackErrs[0]
+	}
+
+	toRollback := make([]executedHandler, 0, len(executed)+1)
+	if failed != nil {
+		toRollback = append(toRollback, *failed)
+	}
+	for i := len(executed) - 1; i >= 0; i-- {
+		toRollback = append(toRollback, executed[i])
+	}
+
+	// Rollback, even when the context of the transition is done
+	rollbackCtx := detachedContext{parent: ctx}
+	for _, h := range toRollback {
+		rollbackErr := callSafely(handlerName(h.handler), func() error {
+			return h.contextHandler.Rollback(rollbackCtx, tc)
+		})
+		sm.reportPanic(rollbackCtx, tc, rollbackErr)
+		if rollbackErr == nil {
+			continue
+		}
+		// Log failure in the handler chain
+		sm.LogErr(fmt.Errorf("Handler %s failed to rollback for eventID %s: %w", handlerName(h.handler), so.EventID, rollbackErr))
+		rollbackErrs = append(rollbackErrs, fmt.Errorf("handler %s: %w", handlerName(h.handler), rollbackErr))
+		if policy != RollbackBestEffort {
+			break
+		}
+	}
+
+	switch len(rollbackErrs) {
+	case 0:
+		return nil
+	case 1:
+		return rollbackErrs[0]
+	default:
+		return rollbackFailures(rollbackErrs)
+	}
+}
+
+// handlerList returns the ordered handlers of the transition. Transitions
+// built by hand with only a Chain are walked through Next.
+func (t *StateTransition) handlerList() []Handler {
+	if t.Handlers != nil {
+		return t.Handlers
+	}
+	var handlers []Handler
+	for handler := t.Chain; handler != nil; handler = handler.Next() {
+		handlers = append(handlers, handler)
+	}
+	return handlers
+}
+
 func (sm *StateMachine) isProcessed(eventID string) bool {
 	exists, err := sm.redisClient.Exists(context.Background(), eventID).Result()
 	if err != nil {
@@ -24,6 +24,14 @@ func init() {
 	mockRedisClient = redis.NewClient(&redis.Options{})
 }
 
+// newTestStateMachine returns a StateMachine whose Redis client gives up
+// immediately, so transitions don't wait on retries when no server is running.
+func newTestStateMachine() *StateMachine {
+	sm := NewStateMachine("localhost:6379")
+	sm.redisClient = redis.NewClient(&redis.Options{Addr: "localhost:6379", MaxRetries: -1})
+	return sm
+}
+
 func TestSetHandlerConfig(t *testing.T) {
 	sm := &StateMachine{}
 	config := HandlerConfig{
@@ -103,11 +111,11 @@ func TestStateMachineEmitEvent(t *testing.T) {
 	sm.EmitEvent("Test event") // This should not produce an error
 }
 
-func TestGetHandlerType(t *testing.T) {
+func TestHandlerSlot(t *testing.T) {
 	handler := &CheckEventIDHandler{}
-	handlerType := getHandlerType(handler)
-	if handlerType != "CheckEventIDHandler" {
-		t.Fatalf("Expected handler type to be CheckEventIDHandler but got %s", handlerType)
+	slot := handlerSlot(handler)
+	if slot != SlotEventID {
+		t.Fatalf("Expected handler slot to be %s but got %s", SlotEventID, slot)
 	}
 }
 
@@ -153,3 +161,229 @@ func TestStateMachineMarkProcessed(t *testing.T) {
 	sm.redisClient = mockRedisClient
 	sm.markProcessed("test_event") // This should not produce an error
 }
+
+func TestRegisterTransitionWildcard(t *testing.T) {
+	sm := NewStateMachine("localhost:6379")
+	sm.RegisterTransition(AnyState, ManualReview)
+
+	for _, from := range []string{SIMNotActivated, SIMActivated, BillingFailed} {
+		transition, exists := sm.lookupTransition(from, ManualReview)
+		if !exists {
+			t.Fatalf("Expected wildcard transition from %s to %s", from, ManualReview)
+		}
+		if transition.From != AnyState {
+			t.Errorf("Expected wildcard source but got %s", transition.From)
+		}
+	}
+
+	if _, exists := sm.lookupTransition(ManualReview, ManualReview); exists {
+		t.Fatalf("Wildcard transition should not match its own target state")
+	}
+}
+
+func TestRegisterTransitionFromAnyExcept(t *testing.T) {
+	sm := NewStateMachine("localhost:6379")
+	sm.RegisterTransitionFromAnyExcept([]string{SIMDeactivated}, ManualReview)
+
+	if _, exists := sm.lookupTransition(SIMActivated, ManualReview); !exists {
+		t.Fatalf("Expected wildcard transition from %s", SIMActivated)
+	}
+	if _, exists := sm.lookupTransition(SIMDeactivated, ManualReview); exists {
+		t.Fatalf("Excluded state %s should not match the wildcard", SIMDeactivated)
+	}
+}
+
+func TestRegisterTransitionFromMany(t *testing.T) {
+	sm := NewStateMachine("localhost:6379")
+	sm.RegisterTransitionFromMany([]string{BillingPaid, BillingFailed}, SIMDeactivated)
+
+	paid, exists := sm.lookupTransition(BillingPaid, SIMDeactivated)
+	if !exists {
+		t.Fatalf("Expected transition from %s", BillingPaid)
+	}
+	failed, exists := sm.lookupTransition(BillingFailed, SIMDeactivated)
+	if !exists {
+		t.Fatalf("Expected transition from %s", BillingFailed)
+	}
+	if paid.Chain != failed.Chain {
+		t.Errorf("Expected source set to share a single handler chain")
+	}
+	if _, exists := sm.lookupTransition(SIMActivated, SIMDeactivated); exists {
+		t.Fatalf("State outside the source set should not match")
+	}
+}
+
+func TestRegisterTransitionFromManySkipsTarget(t *testing.T) {
+	sm := newTestStateMachine()
+	transition := sm.RegisterTransitionFromMany([]string{BillingPaid, SIMDeactivated}, SIMDeactivated)
+
+	if _, exists := sm.lookupTransition(SIMDeactivated, SIMDeactivated); exists {
+		t.Errorf("Expected no transition from the target to itself")
+	}
+	if _, exists := sm.lookupTransition(BillingPaid, SIMDeactivated); !exists {
+		t.Errorf("Expected the other sources to be registered")
+	}
+	if len(transition.Sources) != 1 || transition.Sources[0] != BillingPaid {
+		t.Errorf("Expected the target to be left out of the sources but got %v", transition.Sources)
+	}
+}
+
+func TestTransitionPrecedence(t *testing.T) {
+	sm := NewStateMachine("localhost:6379")
+	sm.RegisterTransition(SIMActivated, ManualReview)
+	sm.RegisterTransitionFromMany([]string{SIMActivated, BillingFailed}, ManualReview)
+	sm.RegisterTransition(AnyState, ManualReview)
+
+	exact, _ := sm.lookupTransition(SIMActivated, ManualReview)
+	if exact.From != SIMActivated {
+		t.Errorf("Expected exact transition to win but got source %s", exact.From)
+	}
+
+	set, _ := sm.lookupTransition(BillingFailed, ManualReview)
+	if len(set.Sources) == 0 {
+		t.Errorf("Expected source set to win over wildcard")
+	}
+
+	wildcard, _ := sm.lookupTransition(SIMNotActivated, ManualReview)
+	if wildcard.From != AnyState || len(wildcard.Sources) != 0 {
+		t.Errorf("Expected wildcard transition for unlisted source state")
+	}
+}
+
+func TestSelfTransition(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.RegisterSelfTransition(SIMActivated)
+
+	var exited, entered int
+	sm.OnExit(SIMActivated, func(so *StateObject) { exited++ })
+	sm.OnEnter(SIMActivated, func(so *StateObject) { entered++ })
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	so.State = SIMActivated
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("Self transition failed: %v", err)
+	}
+	if exited != 1 || entered != 1 {
+		t.Errorf("Expected exit and entry hooks to run once but got %d and %d", exited, entered)
+	}
+	if transition, _ := sm.lookupTransition(SIMActivated, SIMActivated); transition.Kind != SelfTransition {
+		t.Errorf("Expected transition kind to be SelfTransition")
+	}
+}
+
+type setDataHandler struct {
+	MockHandler
+	key   string
+	value interface{}
+}
+
+func (h *setDataHandler) Handle(so *StateObject, state string) bool {
+	so.Data[h.key] = h.value
+	return true
+}
+
+func TestTransitionInternal(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.RegisterInternalTransition(SIMActivated, "UpdateBilling", &setDataHandler{key: "billing", value: "card"})
+
+	var hooks int
+	sm.OnExit(SIMActivated, func(so *StateObject) { hooks++ })
+	sm.OnEnter(SIMActivated, func(so *StateObject) { hooks++ })
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	so.State = SIMActivated
+	if err := so.TransitionInternal(sm, "UpdateBilling"); err != nil {
+		t.Fatalf("Internal transition failed: %v", err)
+	}
+	if so.State != SIMActivated {
+		t.Errorf("Expected state to stay %s but got %s", SIMActivated, so.State)
+	}
+	if so.Data["billing"] != "card" {
+		t.Errorf("Expected handler to update Data but got %v", so.Data)
+	}
+	if hooks != 0 {
+		t.Errorf("Internal transition should not run exit or entry hooks")
+	}
+
+	so.State = SIMDeactivated
+	if err := so.TransitionInternal(sm, "UpdateBilling"); err == nil {
+		t.Fatalf("Internal transition should fail in a state it is not registered for")
+	}
+}
+
+type countingHandler struct {
+	MockHandler
+	calls int
+}
+
+func (h *countingHandler) Handle(so *StateObject, state string) bool {
+	h.calls++
+	return true
+}
+
+func TestTransitionRunsEachHandlerOnce(t *testing.T) {
+	sm := newTestStateMachine()
+	first := &countingHandler{}
+	second := &countingHandler{}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, first, second)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+	if first.calls != 1 || second.calls != 1 {
+		t.Errorf("Expected each handler to run once but got %d and %d", first.calls, second.calls)
+	}
+}
+
+func TestRegisterTransitionHandlerOrder(t *testing.T) {
+	sm := newTestStateMachine()
+	custom := &MockHandler{}
+	override := &CustomTelemetryHandler{}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, custom, override)
+
+	transition := sm.transitions[SIMNotActivated+"->"+SIMActivated]
+	expected := []string{
+		SlotEventID,
+		SlotDedupe,
+		SlotTelemetry,
+		SlotAlerting,
+		"*statemachine.MockHandler",
+		SlotMarkProcessed,
+	}
+	if len(transition.Handlers) != len(expected) {
+		t.Fatalf("Expected %d handlers but got %d", len(expected), len(transition.Handlers))
+	}
+	for i, handler := range transition.Handlers {
+		if name := handlerName(handler); name != expected[i] {
+			t.Errorf("Expected handler %d to be %s but got %s", i, expected[i], name)
+		}
+	}
+	if transition.Handlers[2] != override {
+		t.Errorf("Expected the custom telemetry handler to replace the default one")
+	}
+
+	// The compatibility chain follows the same order
+	i := 0
+	for handler := transition.Chain; handler != nil; handler = handler.Next() {
+		if handler != transition.Handlers[i] {
+			t.Fatalf("Expected chain link %d to match the handler list", i)
+		}
+		i++
+	}
+}
+
+func TestRegisterTransitionWithoutDefaultHandlers(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	handler := &countingHandler{}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, handler)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+	if handler.calls != 1 {
+		t.Errorf("Expected handler to run once but got %d", handler.calls)
+	}
+}
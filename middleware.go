@@ -0,0 +1,214 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// HandlerFunc adapts a function to a ContextHandler that has nothing to roll
+// back.
+type HandlerFunc func(context.Context, *TransitionContext) error
+
+func (f HandlerFunc) Handle(ctx context.Context, tc *TransitionContext) error {
+	return f(ctx, tc)
+}
+
+func (f HandlerFunc) Rollback(ctx context.Context, tc *TransitionContext) error {
+	return nil
+}
+
+// RollbackFunc is the rollback half of a handler built with NewHandler.
+type RollbackFunc func(context.Context, *TransitionContext) error
+
+// NewHandler builds a Handler from functions, ready to be registered with a
+// transition. The name is its slot; leave it empty for a plain custom
+// handler. A nil rollback means there is nothing to roll back.
+func NewHandler(name string, handle HandlerFunc, rollback RollbackFunc) Handler {
+	return FromContextHandler(&funcHandler{name: name, handle: handle, rollback: rollback})
+}
+
+type funcHandler struct {
+	name     string
+	handle   HandlerFunc
+	rollback RollbackFunc
+}
+
+func (h *funcHandler) Name() string {
+	return h.name
+}
+
+func (h *funcHandler) Handle(ctx context.Context, tc *TransitionContext) error {
+	return h.handle(ctx, tc)
+}
+
+func (h *funcHandler) Rollback(ctx context.Context, tc *TransitionContext) error {
+	if h.rollback == nil {
+		return nil
+	}
+	return h.rollback(ctx, tc)
+}
+
+// Middleware wraps a handler with extra behaviour around Handle and
+// Rollback.
+type Middleware func(ContextHandler) ContextHandler
+
+// Use wraps every handler of every transition with the middleware. The
+// first middleware given is the outermost.
+func (sm *StateMachine) Use(middleware ...Middleware) {
+	sm.middleware = append(sm.middleware, middleware...)
+}
+
+// Use wraps every handler of this transition with the middleware. It runs
+// inside the middleware registered on the StateMachine.
+func (t *StateTransition) Use(middleware ...Middleware) *StateTransition {
+	t.middleware = append(t.middleware, middleware...)
+	return t
+}
+
+// wrap applies the middleware of the machine and the transition to a
+// handler.
+func (t *StateTransition) wrap(sm *StateMachine, h ContextHandler) ContextHandler {
+	for i := len(t.middleware) - 1; i >= 0; i-- {
+		h = t.middleware[i](h)
+	}
+	for i := len(sm.middleware) - 1; i >= 0; i-- {
+		h = sm.middleware[i](h)
+	}
+	return h
+}
+
+// middlewareHandler is a ContextHandler built from functions that receive
+// the handler being wrapped.
+type middlewareHandler struct {
+	next     ContextHandler
+	handle   func(context.Context, *TransitionContext, ContextHandler) error
+	rollback func(context.Context, *TransitionContext, ContextHandler) error
+}
+
+func (h *middlewareHandler) Name() string {
+	return contextHandlerName(h.next)
+}
+
+func (h *middlewareHandler) Handle(ctx context.Context, tc *TransitionContext) error {
+	if h.handle == nil {
+		return h.next.Handle(ctx, tc)
+	}
+	return h.handle(ctx, tc, h.next)
+}
+
+func (h *middlewareHandler) Rollback(ctx context.Context, tc *TransitionContext) error {
+	if h.rollback == nil {
+		return h.next.Rollback(ctx, tc)
+	}
+	return h.rollback(ctx, tc, h.next)
+}
+
+// Timeout gives every Handle and Rollback call its own deadline.
+func Timeout(d time.Duration) Middleware {
+	withTimeout := func(ctx context.Context, tc *TransitionContext, call func(context.Context, *TransitionContext) error) error {
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return call(ctx, tc)
+	}
+	return func(next ContextHandler) ContextHandler {
+		return &middlewareHandler{
+			next: next,
+			handle: func(ctx context.Context, tc *TransitionContext, next ContextHandler) error {
+				return withTimeout(ctx, tc, next.Handle)
+			},
+			rollback: func(ctx context.Context, tc *TransitionContext, next ContextHandler) error {
+				return withTimeout(ctx, tc, next.Rollback)
+			},
+		}
+	}
+}
+
+// WithTimeout gives the handler chain of the transition a deadline. Unlike
+// the Timeout middleware, it bounds all handlers together. Rollbacks are not
+// bound by it.
+func (t *StateTransition) WithTimeout(d time.Duration) *StateTransition {
+	t.Timeout = d
+	return t
+}
+
+// Retry calls Handle up to attempts times, waiting delay between attempts,
+// until it no longer fails. Use WithRetryPolicy for backoff, jitter and
+// attempts recorded in the transition log.
+func Retry(attempts int, delay time.Duration) Middleware {
+	policy := RetryPolicy{
+		MaxAttempts:    attempts,
+		InitialBackoff: delay,
+		Multiplier:     1,
+		Retryable:      func(error) bool { return true },
+	}
+	return func(next ContextHandler) ContextHandler {
+		return &middlewareHandler{
+			next: next,
+			handle: func(ctx context.Context, tc *TransitionContext, next ContextHandler) error {
+				return policy.do(ctx, func() error {
+					return next.Handle(ctx, tc)
+				}, nil)
+			},
+		}
+	}
+}
+
+// Logging logs every Handle and Rollback call with its duration and error.
+func Logging(logger *zap.Logger) Middleware {
+	logCall := func(ctx context.Context, tc *TransitionContext, call string, next ContextHandler, fn func(context.Context, *TransitionContext) error) error {
+		start := nowFunc()
+		err := fn(ctx, tc)
+		logger.Debug(call,
+			zap.String("handler", contextHandlerName(next)),
+			zap.String("event_id", tc.Object.EventID),
+			zap.String("from_state", tc.From),
+			zap.String("to_state", tc.To),
+			zap.Duration("duration", nowFunc().Sub(start)),
+			zap.Error(err),
+		)
+		return err
+	}
+	return func(next ContextHandler) ContextHandler {
+		return &middlewareHandler{
+			next: next,
+			handle: func(ctx context.Context, tc *TransitionContext, next ContextHandler) error {
+				return logCall(ctx, tc, "handle", next, next.Handle)
+			},
+			rollback: func(ctx context.Context, tc *TransitionContext, next ContextHandler) error {
+				return logCall(ctx, tc, "rollback", next, next.Rollback)
+			},
+		}
+	}
+}
+
+// Recover turns a panic in Handle or Rollback into a *PanicError.
+// TransitionTo already recovers panics of the handlers it runs; Recover is
+// for handlers called elsewhere, or to stop a panic before it reaches other
+// middleware.
+func Recover() Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return &middlewareHandler{
+			next: next,
+			handle: func(ctx context.Context, tc *TransitionContext, next ContextHandler) error {
+				return callSafely(contextHandlerName(next), func() error { return next.Handle(ctx, tc) })
+			},
+			rollback: func(ctx context.Context, tc *TransitionContext, next ContextHandler) error {
+				return callSafely(contextHandlerName(next), func() error { return next.Rollback(ctx, tc) })
+			},
+		}
+	}
+}
+
+// contextHandlerName returns the name used for a ContextHandler in logs.
+func contextHandlerName(h ContextHandler) string {
+	if named, ok := h.(Named); ok && named.Name() != "" {
+		return named.Name()
+	}
+	if legacy, ok := h.(legacyHandler); ok {
+		return handlerName(legacy.handler)
+	}
+	return fmt.Sprintf("%T", h)
+}
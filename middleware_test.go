@@ -0,0 +1,114 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNewHandler(t *testing.T) {
+	sm := newTestStateMachine()
+	var rolledBack bool
+	reserve := NewHandler("reserve", func(ctx context.Context, tc *TransitionContext) error {
+		tc.Object.Data["reserved"] = true
+		return nil
+	}, func(ctx context.Context, tc *TransitionContext) error {
+		rolledBack = true
+		delete(tc.Object.Data, "reserved")
+		return nil
+	})
+	failing := FromContextHandler(HandlerFunc(func(ctx context.Context, tc *TransitionContext) error {
+		return errCarrierUnavailable
+	}))
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, reserve, failing)
+
+	if handlerSlot(reserve) != "reserve" {
+		t.Errorf("Expected handler to be named reserve but got %s", handlerSlot(reserve))
+	}
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); !errors.Is(err, errCarrierUnavailable) {
+		t.Fatalf("Expected handler error but got %v", err)
+	}
+	if !rolledBack {
+		t.Errorf("Expected rollback function to run")
+	}
+	if _, ok := so.Data["reserved"]; ok {
+		t.Errorf("Expected rollback function to undo the handler")
+	}
+}
+
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next ContextHandler) ContextHandler {
+		return HandlerFunc(func(ctx context.Context, tc *TransitionContext) error {
+			*calls = append(*calls, name)
+			return next.Handle(ctx, tc)
+		})
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	var calls []string
+	sm.Use(recordingMiddleware("global", &calls))
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, &MockHandler{}).
+		Use(recordingMiddleware("transition", &calls))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+	if expected := []string{"global", "transition"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected middleware calls %v but got %v", expected, calls)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	handler := Timeout(10 * time.Millisecond)(HandlerFunc(func(ctx context.Context, tc *TransitionContext) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	err := handler.Handle(context.Background(), &TransitionContext{Object: &StateObject{}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded but got %v", err)
+	}
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	attempts := 0
+	handler := Retry(3, time.Millisecond)(HandlerFunc(func(ctx context.Context, tc *TransitionContext) error {
+		attempts++
+		if attempts < 3 {
+			return errCarrierUnavailable
+		}
+		return nil
+	}))
+	if err := handler.Handle(context.Background(), &TransitionContext{Object: &StateObject{}}); err != nil {
+		t.Fatalf("Expected the third attempt to succeed but got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts but got %d", attempts)
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	handler := Recover()(HandlerFunc(func(ctx context.Context, tc *TransitionContext) error {
+		panic("carrier client not configured")
+	}))
+	if err := handler.Handle(context.Background(), &TransitionContext{Object: &StateObject{}}); err == nil {
+		t.Fatalf("Expected the panic to be returned as an error")
+	}
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	handler := Logging(zaptest.NewLogger(t))(&mockContextHandler{err: errCarrierUnavailable})
+	err := handler.Handle(context.Background(), &TransitionContext{Object: &StateObject{EventID: "event"}})
+	if !errors.Is(err, errCarrierUnavailable) {
+		t.Fatalf("Expected logging middleware to pass the error through but got %v", err)
+	}
+}
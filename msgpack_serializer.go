@@ -0,0 +1,104 @@
+package statemachine
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ContentTypeMsgpack is the content type of MsgpackSerialization.
+const ContentTypeMsgpack = "application/msgpack"
+
+// MsgpackSerialization writes objects as MessagePack, with the field names
+// of JSONSerialization. Unlike JSON, integers in Data are read back as
+// int64, or uint64 above math.MaxInt64, byte slices as []byte and times as
+// time.Time.
+type MsgpackSerialization struct{}
+
+// ContentType returns ContentTypeMsgpack.
+func (m MsgpackSerialization) ContentType() string {
+	return ContentTypeMsgpack
+}
+
+func (m MsgpackSerialization) Serialize(so *StateObject) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(newBinaryObject(so)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m MsgpackSerialization) Deserialize(data []byte) (*StateObject, error) {
+	var so StateObject
+	if err := m.DeserializeInto(data, &so); err != nil {
+		return nil, err
+	}
+	return &so, nil
+}
+
+// DeserializeInto decodes into an existing StateObject, so the data of a
+// TypedStateObject is decoded straight into its type.
+func (m MsgpackSerialization) DeserializeInto(data []byte, so *StateObject) error {
+	var obj struct {
+		ID      string             `json:"id,omitempty"`
+		Data    msgpack.RawMessage `json:"data"`
+		State   string             `json:"state"`
+		EventID string             `json:"eventID"`
+	}
+	if err := newMsgpackDecoder(data).Decode(&obj); err != nil {
+		return err
+	}
+	so.ID, so.State, so.EventID = obj.ID, obj.State, obj.EventID
+	if so.typed != nil {
+		return newMsgpackDecoder(obj.Data).Decode(so.typed)
+	}
+	so.Data = nil
+	if err := newMsgpackDecoder(obj.Data).Decode(&so.Data); err != nil {
+		return err
+	}
+	for key, value := range so.Data {
+		so.Data[key] = widenInts(value)
+	}
+	return nil
+}
+
+// widenInts turns the integers MessagePack decodes with the width they
+// were written with into int64, or uint64 when they don't fit.
+func widenInts(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = widenInts(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = widenInts(value)
+		}
+	}
+	return v
+}
+
+func newMsgpackDecoder(data []byte) *msgpack.Decoder {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec
+}
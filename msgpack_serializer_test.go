@@ -0,0 +1,11 @@
+package statemachine
+
+import "testing"
+
+func TestMsgpackPreservesTypes(t *testing.T) {
+	testPreservesTypes(t, MsgpackSerialization{})
+}
+
+func BenchmarkMsgpackRoundTrip(b *testing.B) {
+	benchmarkRoundTrip(b, MsgpackSerialization{})
+}
@@ -0,0 +1,204 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Parallel groups independent handlers that run concurrently with a shared
+// context. The group fails if any member fails; the other members are then
+// cancelled and the members that succeeded are rolled back before the
+// transition rolls back the handlers that ran before the group.
+//
+// Members run at the same time, so those that touch Object.Data must hold
+// the TransitionContext lock while doing so.
+func Parallel(handlers ...Handler) Handler {
+	return &parallelHandler{members: handlers}
+}
+
+type parallelHandler struct {
+	next    Handler
+	members []Handler
+}
+
+func (h *parallelHandler) Name() string {
+	names := make([]string, len(h.members))
+	for i, member := range h.members {
+		names[i] = handlerName(member)
+	}
+	return "parallel(" + strings.Join(names, ",") + ")"
+}
+
+func (h *parallelHandler) Handle(so *StateObject, state string) bool {
+	return h.contextHandler().Handle(context.Background(), &TransitionContext{Object: so, From: so.State, To: state, Log: &StateTransitionLog{}}) == nil
+}
+
+func (h *parallelHandler) Rollback(so *StateObject, state string) bool {
+	return h.contextHandler().Rollback(context.Background(), &TransitionContext{Object: so, From: so.State, To: state, Log: &StateTransitionLog{}}) == nil
+}
+
+func (h *parallelHandler) SetNext(handler Handler) {
+	h.next = handler
+}
+
+func (h *parallelHandler) Next() Handler {
+	return h.next
+}
+
+func (h *parallelHandler) contextHandler() ContextHandler {
+	return parallelContextHandler{handler: h}
+}
+
+type parallelContextHandler struct {
+	handler *parallelHandler
+}
+
+func (h parallelContextHandler) Handle(ctx context.Context, tc *TransitionContext) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	members := h.handler.members
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func(i int, member Handler) {
+			defer wg.Done()
+			errs[i] = callSafely(handlerName(member), func() error {
+				return tc.handle(ctx, member, memberHandler(tc, member))
+			})
+			tc.Machine.reportPanic(ctx, tc, errs[i])
+			if OutcomeOf(errs[i]) == OutcomeFail {
+				cancel()
+			}
+		}(i, member)
+	}
+	wg.Wait()
+
+	parallelErr := &ParallelError{}
+	var succeeded []Handler
+	for i, err := range errs {
+		if OutcomeOf(err) == OutcomeFail {
+			parallelErr.Failures = append(parallelErr.Failures, &HandlerError{Handler: handlerName(members[i]), EventID: tc.Object.EventID, Err: err})
+			continue
+		}
+		succeeded = append(succeeded, members[i])
+	}
+	if len(parallelErr.Failures) == 0 {
+		h.setCompleted(tc, succeeded)
+		return nil
+	}
+
+	// The members that succeeded are rolled back here, so rolling back the
+	// group later leaves them alone
+	h.setCompleted(tc, nil)
+	parallelErr.Rollback = rollbackConcurrently(detachedContext{parent: ctx}, tc, succeeded)
+	return parallelErr
+}
+
+// Rollback rolls back the members that completed and were not rolled back
+// yet, or every member when the group didn't run with this context.
+func (h parallelContextHandler) Rollback(ctx context.Context, tc *TransitionContext) error {
+	tc.Lock()
+	members, ran := tc.completed[h.handler]
+	delete(tc.completed, h.handler)
+	tc.Unlock()
+	if !ran {
+		members = h.handler.members
+	}
+	return rollbackConcurrently(ctx, tc, members)
+}
+
+func (h parallelContextHandler) setCompleted(tc *TransitionContext, members []Handler) {
+	tc.Lock()
+	defer tc.Unlock()
+	if tc.completed == nil {
+		tc.completed = make(map[*parallelHandler][]Handler)
+	}
+	tc.completed[h.handler] = members
+}
+
+// memberHandler adapts a member of a group and wraps it with the middleware
+// of the running transition, like the handlers of the chain.
+func memberHandler(tc *TransitionContext, member Handler) ContextHandler {
+	if tc.transition == nil || tc.Machine == nil {
+		return AdaptHandler(member)
+	}
+	return tc.transition.wrap(tc.Machine, AdaptHandler(member))
+}
+
+// rollbackConcurrently rolls back the handlers at the same time and reports
+// the handlers that could not be rolled back.
+func rollbackConcurrently(ctx context.Context, tc *TransitionContext, handlers []Handler) error {
+	errs := make([]error, len(handlers))
+	var wg sync.WaitGroup
+	for i, handler := range handlers {
+		wg.Add(1)
+		go func(i int, handler Handler) {
+			defer wg.Done()
+			err := callSafely(handlerName(handler), func() error {
+				return memberHandler(tc, handler).Rollback(ctx, tc)
+			})
+			tc.Machine.reportPanic(ctx, tc, err)
+			if err != nil {
+				errs[i] = fmt.Errorf("handler %s: %w", handlerName(handler), err)
+			}
+		}(i, handler)
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrRollbackFailed, strings.Join(failed, "; "))
+}
+
+// ParallelError is returned by a Parallel group when members failed. It
+// records the failures and the outcome of rolling back the members that
+// succeeded.
+type ParallelError struct {
+	Failures []*HandlerError
+	Rollback error
+}
+
+func (e *ParallelError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		failures[i] = failure.Error()
+	}
+	msg := "parallel handlers failed: " + strings.Join(failures, "; ")
+	if e.Rollback != nil {
+		msg += fmt.Sprintf("; %v", e.Rollback)
+	}
+	return msg
+}
+
+func (e *ParallelError) Unwrap() error {
+	if len(e.Failures) == 0 {
+		return nil
+	}
+	return e.Failures[0]
+}
+
+// Is matches the errors of every failed member, and ErrRollbackFailed when
+// a member that succeeded could not be rolled back.
+func (e *ParallelError) Is(target error) bool {
+	if target == ErrRollbackFailed {
+		return e.Rollback != nil
+	}
+	for _, failure := range e.Failures {
+		if errors.Is(failure, target) {
+			return true
+		}
+	}
+	return false
+}
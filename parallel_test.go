@@ -0,0 +1,195 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// barrierHandler only succeeds once every member of its group has started,
+// which can only happen when the members run concurrently.
+type barrierHandler struct {
+	mockContextHandler
+	barrier *sync.WaitGroup
+}
+
+func (h *barrierHandler) Handle(ctx context.Context, tc *TransitionContext) error {
+	h.barrier.Done()
+	done := make(chan struct{})
+	go func() {
+		h.barrier.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(time.Second):
+		return errors.New("members did not run concurrently")
+	}
+}
+
+type countingContextHandler struct {
+	mu          sync.Mutex
+	err         error
+	rollbackErr error
+	handled     int
+	rolledBack  int
+}
+
+func (h *countingContextHandler) Handle(ctx context.Context, tc *TransitionContext) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handled++
+	return h.err
+}
+
+func (h *countingContextHandler) Rollback(ctx context.Context, tc *TransitionContext) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rolledBack++
+	return h.rollbackErr
+}
+
+func TestParallelRunsMembersConcurrently(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	var barrier sync.WaitGroup
+	barrier.Add(3)
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, Parallel(
+		FromContextHandler(&barrierHandler{barrier: &barrier}),
+		FromContextHandler(&barrierHandler{barrier: &barrier}),
+		FromContextHandler(&barrierHandler{barrier: &barrier}),
+	))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+}
+
+func TestParallelRollsBackSucceededMembers(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	before := &countingContextHandler{}
+	crm := &countingContextHandler{}
+	inventory := &countingContextHandler{err: errCarrierUnavailable}
+	cache := &countingContextHandler{}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		FromContextHandler(before),
+		Parallel(FromContextHandler(crm), FromContextHandler(inventory), FromContextHandler(cache)),
+	)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	if !errors.Is(err, errCarrierUnavailable) {
+		t.Fatalf("Expected the member error to be reported but got %v", err)
+	}
+	var parallelErr *ParallelError
+	if !errors.As(err, &parallelErr) || len(parallelErr.Failures) != 1 {
+		t.Fatalf("Expected a *ParallelError with one failure but got %v", err)
+	}
+	if crm.rolledBack != 1 || cache.rolledBack != 1 {
+		t.Errorf("Expected the members that succeeded to be rolled back")
+	}
+	if inventory.rolledBack != 0 {
+		t.Errorf("The failing member should not be rolled back")
+	}
+	if before.rolledBack != 1 {
+		t.Errorf("Expected the handler before the group to be rolled back")
+	}
+	if so.State != SIMNotActivated {
+		t.Errorf("Expected state to stay %s but got %s", SIMNotActivated, so.State)
+	}
+}
+
+func TestParallelRolledBackByLaterFailure(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	crm := &countingContextHandler{}
+	cache := &countingContextHandler{}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		Parallel(FromContextHandler(crm), FromContextHandler(cache)),
+		FromContextHandler(&countingContextHandler{err: errCarrierUnavailable}),
+	)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err == nil {
+		t.Fatalf("Expected transition to fail")
+	}
+	if crm.rolledBack != 1 || cache.rolledBack != 1 {
+		t.Errorf("Expected every member to be rolled back")
+	}
+}
+
+func TestParallelMemberRollbackFailure(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, Parallel(
+		FromContextHandler(&countingContextHandler{rollbackErr: errors.New("cannot refund")}),
+		FromContextHandler(&countingContextHandler{err: errCarrierUnavailable}),
+	))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	if !errors.Is(err, ErrRollbackFailed) {
+		t.Fatalf("Expected rollback failure to be reported but got %v", err)
+	}
+	if so.State != ManualReview {
+		t.Errorf("Expected state to be %s but got %s", ManualReview, so.State)
+	}
+}
+
+func TestParallelMembersRunThroughMiddleware(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	var mu sync.Mutex
+	wrapped := make(map[string]int)
+	count := func(scope string) Middleware {
+		return func(next ContextHandler) ContextHandler {
+			return &middlewareHandler{next: next, handle: func(ctx context.Context, tc *TransitionContext, next ContextHandler) error {
+				mu.Lock()
+				wrapped[scope]++
+				mu.Unlock()
+				return next.Handle(ctx, tc)
+			}}
+		}
+	}
+	sm.Use(count("machine"))
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		Parallel(FromContextHandler(&countingContextHandler{}), FromContextHandler(&countingContextHandler{})),
+	).Use(count("transition"))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("TransitionTo failed: %v", err)
+	}
+	// Once for the group and once for each member
+	if wrapped["machine"] != 3 || wrapped["transition"] != 3 {
+		t.Errorf("Expected the middleware to wrap every member but got %v", wrapped)
+	}
+}
+
+func TestCompensatingParallelRollsBackMembersOnce(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	crm := &countingContextHandler{}
+	inventory := &countingContextHandler{err: errCarrierUnavailable}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		CompensateOnFailure(Parallel(FromContextHandler(crm), FromContextHandler(inventory))),
+	)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); !errors.Is(err, errCarrierUnavailable) {
+		t.Fatalf("Expected the member error to be reported but got %v", err)
+	}
+	if crm.rolledBack != 1 {
+		t.Errorf("Expected the member that succeeded to be rolled back once but got %d", crm.rolledBack)
+	}
+	if inventory.rolledBack != 0 {
+		t.Errorf("The failing member should not be rolled back")
+	}
+}
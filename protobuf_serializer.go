@@ -0,0 +1,155 @@
+package statemachine
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/hibrid/statemachine/serialization/protomodels"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ContentTypeProtobuf is the content type of ProtobufSerialization.
+const ContentTypeProtobuf = "application/x-protobuf; messageType=hibrid.statemachine.v1.StateObject"
+
+// protobufVersion is the version of the envelope written by
+// ProtobufSerialization.
+const protobufVersion = 1
+
+// ProtobufSerialization writes objects as the StateObject message of
+// serialization/protomodels/state_object.proto. Data is written as a
+// google.protobuf.Struct. A TypedStateObject whose data is a pointer to a
+// protobuf message is written as a google.protobuf.Any holding the message.
+type ProtobufSerialization struct{}
+
+// ContentType returns ContentTypeProtobuf.
+func (p ProtobufSerialization) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (p ProtobufSerialization) Serialize(so *StateObject) ([]byte, error) {
+	envelope := &protomodels.StateObject{
+		Version:   protobufVersion,
+		Id:        so.ID,
+		State:     so.State,
+		EventId:   so.EventID,
+		WrittenAt: timestamppb.New(nowFunc()),
+	}
+
+	if msg, ok := typedMessage(so.typed, false); ok {
+		data, err := anypb.New(msg)
+		if err != nil {
+			return nil, err
+		}
+		envelope.Data = &protomodels.StateObject_MessageData{MessageData: data}
+	} else if data := so.dataValue(); data != nil {
+		s, err := jsonStruct(data)
+		if err != nil {
+			return nil, err
+		}
+		envelope.Data = &protomodels.StateObject_StructData{StructData: s}
+	}
+	return proto.Marshal(envelope)
+}
+
+func (p ProtobufSerialization) Deserialize(data []byte) (*StateObject, error) {
+	var so StateObject
+	if err := p.DeserializeInto(data, &so); err != nil {
+		return nil, err
+	}
+	return &so, nil
+}
+
+// DeserializeInto decodes into an existing StateObject. The message of a
+// TypedStateObject is decoded into its typed data. Without typed data, a
+// message is decoded into Data in its JSON form, with an "@type" key naming
+// it, which needs the message type to be linked into the program.
+func (p ProtobufSerialization) DeserializeInto(data []byte, so *StateObject) error {
+	var envelope protomodels.StateObject
+	if err := proto.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	if envelope.Version != protobufVersion {
+		return fmt.Errorf("unsupported StateObject envelope version %d", envelope.Version)
+	}
+	so.ID, so.State, so.EventID = envelope.Id, envelope.State, envelope.EventId
+
+	switch d := envelope.Data.(type) {
+	case *protomodels.StateObject_MessageData:
+		if msg, ok := typedMessage(so.typed, true); ok {
+			return d.MessageData.UnmarshalTo(msg)
+		}
+		return decodeJSON(d.MessageData, so)
+	case *protomodels.StateObject_StructData:
+		if so.typed == nil {
+			so.Data = d.StructData.AsMap()
+			return nil
+		}
+		return decodeJSON(d.StructData, so)
+	}
+	return nil
+}
+
+// dataValue returns the typed data of the object if it has any, else Data.
+func (so *StateObject) dataValue() interface{} {
+	if so.typed != nil {
+		return so.typed
+	}
+	if so.Data == nil {
+		return nil
+	}
+	return so.Data
+}
+
+// jsonStruct converts data to a Struct through its JSON form, so any data
+// that JSONSerialization writes can be written.
+func jsonStruct(data interface{}) (*structpb.Struct, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var s structpb.Struct
+	if err := protojson.Unmarshal(encoded, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// decodeJSON decodes the JSON form of a message into the typed data of the
+// object, or into Data.
+func decodeJSON(msg proto.Message, so *StateObject) error {
+	encoded, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if so.typed != nil {
+		return json.Unmarshal(encoded, so.typed)
+	}
+	return json.Unmarshal(encoded, &so.Data)
+}
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// typedMessage returns the data of a TypedStateObject[M] where M is a
+// pointer to a protobuf message. With alloc, a nil message is allocated.
+func typedMessage(typed interface{}, alloc bool) (proto.Message, bool) {
+	v := reflect.ValueOf(typed)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, false
+	}
+	elem := v.Elem()
+	if elem.Kind() != reflect.Ptr || !elem.Type().Implements(protoMessageType) {
+		return nil, false
+	}
+	if elem.IsNil() {
+		if !alloc {
+			return nil, false
+		}
+		elem.Set(reflect.New(elem.Type().Elem()))
+	}
+	return elem.Interface().(proto.Message), true
+}
@@ -0,0 +1,114 @@
+package statemachine
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hibrid/statemachine/serialization/protomodels"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtobufRoundTrip(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{Serializer: ProtobufSerialization{}})
+
+	so := NewStateObject(map[string]interface{}{"iccid": "8901", "carriers": []string{"TelecomProvider"}}, sm, nil)
+	so.ID, so.EventID, so.State = "sim-8901", "evt-1", SIMActivated
+	stored, err := sm.encode(so)
+	if err != nil {
+		t.Fatalf("Encoding failed: %v", err)
+	}
+
+	decoded, err := Deserialize(stored)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if decoded.ID != so.ID || decoded.EventID != so.EventID || decoded.State != so.State || decoded.Data["iccid"] != "8901" {
+		t.Errorf("Expected %+v but got %+v", so, decoded)
+	}
+	if carriers, ok := decoded.Data["carriers"].([]interface{}); !ok || len(carriers) != 1 || carriers[0] != "TelecomProvider" {
+		t.Errorf("Expected the carriers to be read back but got %v", decoded.Data["carriers"])
+	}
+}
+
+// TestProtobufEnvelope reads a serialized object with the published
+// message only, as consumers in other languages do.
+func TestProtobufEnvelope(t *testing.T) {
+	so := NewStateObject(map[string]interface{}{"iccid": "8901"}, nil, nil)
+	so.ID = "sim-8901"
+	data, err := ProtobufSerialization{}.Serialize(so)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	var envelope protomodels.StateObject
+	if err := proto.Unmarshal(data, &envelope); err != nil {
+		t.Fatalf("Failed to read the envelope: %v", err)
+	}
+	if envelope.Version != protobufVersion || envelope.Id != "sim-8901" || envelope.State != SIMNotActivated || envelope.WrittenAt == nil {
+		t.Errorf("Unexpected envelope %v", &envelope)
+	}
+	if envelope.GetStructData().GetFields()["iccid"].GetStringValue() != "8901" {
+		t.Errorf("Expected the data as a struct but got %v", envelope.Data)
+	}
+}
+
+func TestProtobufRejectsUnknownVersion(t *testing.T) {
+	data, err := proto.Marshal(&protomodels.StateObject{Version: protobufVersion + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (ProtobufSerialization{}).Deserialize(data); err == nil {
+		t.Errorf("Expected an unknown envelope version to be rejected")
+	}
+}
+
+func TestProtobufTypedMessage(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{Serializer: ProtobufSerialization{}})
+
+	sim := NewTypedStateObject(wrapperspb.String("8901"), sm, nil)
+	stored, err := sm.encode(sim.StateObject)
+	if err != nil {
+		t.Fatalf("Encoding failed: %v", err)
+	}
+
+	decoded, err := DeserializeTyped[*wrapperspb.StringValue](sm, stored)
+	if err != nil {
+		t.Fatalf("DeserializeTyped failed: %v", err)
+	}
+	if decoded.Data.GetValue() != "8901" {
+		t.Errorf("Expected the message to be read back but got %v", decoded.Data)
+	}
+
+	plain, err := Deserialize(stored)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if plain.Data["@type"] != "type.googleapis.com/google.protobuf.StringValue" || plain.Data["value"] != "8901" {
+		t.Errorf("Expected the JSON form of the message but got %v", plain.Data)
+	}
+}
+
+func TestProtobufTypedStruct(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{Serializer: ProtobufSerialization{}})
+
+	sim := NewTypedStateObject(simCard{ICCID: "8901", IMSI: 310150123456789}, sm, nil)
+	stored, err := sm.encode(sim.StateObject)
+	if err != nil {
+		t.Fatalf("Encoding failed: %v", err)
+	}
+	if !bytes.Contains(stored, []byte(ContentTypeProtobuf)) {
+		t.Errorf("Expected the content type to be stored")
+	}
+
+	decoded, err := DeserializeTyped[simCard](sm, stored)
+	if err != nil {
+		t.Fatalf("DeserializeTyped failed: %v", err)
+	}
+	if decoded.Data.ICCID != "8901" || decoded.Data.IMSI != 310150123456789 {
+		t.Errorf("Expected %+v but got %+v", sim.Data, decoded.Data)
+	}
+}
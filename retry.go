@@ -0,0 +1,169 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how often and how fast a failing handler is retried
+// before the transition is rolled back.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of calls, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts. Zero means no cap.
+	MaxBackoff time.Duration
+	// Multiplier grows the wait after every attempt. Zero means 2.
+	Multiplier float64
+	// Jitter randomises every wait by up to this fraction of it, e.g. 0.2.
+	Jitter float64
+	// Retryable decides which errors are retried. When nil, only errors
+	// marked with MarkRetryable or implementing Retryable() bool are.
+	Retryable func(error) bool
+}
+
+// RetryPolicyProvider is implemented by handlers that are retried by
+// TransitionTo when they fail.
+type RetryPolicyProvider interface {
+	RetryPolicy() RetryPolicy
+}
+
+// WithRetryPolicy attaches a retry policy to a handler. The handler keeps
+// its name, so it can still take the place of a default slot.
+func WithRetryPolicy(h Handler, policy RetryPolicy) Handler {
+	return &retryHandler{Handler: h, policy: policy}
+}
+
+type retryHandler struct {
+	Handler
+	policy RetryPolicy
+}
+
+func (h *retryHandler) Name() string {
+	return handlerSlot(h.Handler)
+}
+
+func (h *retryHandler) RetryPolicy() RetryPolicy {
+	return h.policy
+}
+
+func (h *retryHandler) Unwrap() Handler {
+	return h.Handler
+}
+
+func (h *retryHandler) contextHandler() ContextHandler {
+	return AdaptHandler(h.Handler)
+}
+
+// MarkRetryable marks an error returned by a ContextHandler as transient.
+func MarkRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return retryableError{err: err}
+}
+
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string   { return e.err.Error() }
+func (e retryableError) Unwrap() error   { return e.err }
+func (e retryableError) Retryable() bool { return true }
+
+// IsRetryable reports whether an error, or any error it wraps, is marked as
+// retryable.
+func IsRetryable(err error) bool {
+	var retryable interface{ Retryable() bool }
+	return errors.As(err, &retryable) && retryable.Retryable()
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if OutcomeOf(err) != OutcomeFail {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// backoff returns the wait after the given attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+// do calls fn until it succeeds, fails with an error that is not retryable,
+// or runs out of attempts. Every attempt is reported to record.
+func (p RetryPolicy) do(ctx context.Context, fn func() error, record func(attempt int, err error)) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = fn()
+		if record != nil {
+			record(attempt, err)
+		}
+		if attempt == attempts || !p.retryable(err) {
+			return err
+		}
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+// handle calls the handler, retrying it according to its retry policy and
+// recording every attempt in the transition log.
+func (tc *TransitionContext) handle(ctx context.Context, handler Handler, contextHandler ContextHandler) error {
+	policy, ok := retryPolicyOf(handler)
+	if !ok {
+		return contextHandler.Handle(ctx, tc)
+	}
+	return policy.do(ctx, func() error {
+		return contextHandler.Handle(ctx, tc)
+	}, func(attempt int, err error) {
+		tc.Lock()
+		defer tc.Unlock()
+		tc.Log.Attempts = append(tc.Log.Attempts, HandlerAttempt{
+			Handler:   handlerName(handler),
+			Attempt:   attempt,
+			Err:       err,
+			Timestamp: nowFunc(),
+		})
+	})
+}
+
+// retryPolicyOf returns the retry policy of the handler, or of a handler it
+// wraps.
+func retryPolicyOf(h Handler) (RetryPolicy, bool) {
+	for h != nil {
+		if provider, ok := h.(RetryPolicyProvider); ok {
+			return provider.RetryPolicy(), true
+		}
+		h = unwrapHandler(h)
+	}
+	return RetryPolicy{}, false
+}
@@ -0,0 +1,162 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type flakyHandler struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (h *flakyHandler) Handle(ctx context.Context, tc *TransitionContext) error {
+	h.calls++
+	if h.calls <= h.failures {
+		return h.err
+	}
+	return nil
+}
+
+func (h *flakyHandler) Rollback(ctx context.Context, tc *TransitionContext) error {
+	return nil
+}
+
+func TestRetryPolicyRetriesTransientErrors(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	handler := &flakyHandler{failures: 2, err: MarkRetryable(errCarrierUnavailable)}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		WithRetryPolicy(FromContextHandler(handler), RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("Expected transition to succeed after retries but got %v", err)
+	}
+	if handler.calls != 3 {
+		t.Errorf("Expected 3 calls but got %d", handler.calls)
+	}
+}
+
+func TestRetryPolicyExhausted(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	handler := &flakyHandler{failures: 5, err: MarkRetryable(errCarrierUnavailable)}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		WithRetryPolicy(FromContextHandler(handler), RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	var handlerErr *HandlerError
+	if !errors.As(err, &handlerErr) {
+		t.Fatalf("Expected a *HandlerError but got %v", err)
+	}
+	if handlerErr.Attempts != 3 || handler.calls != 3 {
+		t.Errorf("Expected 3 attempts but got %d", handlerErr.Attempts)
+	}
+	if !errors.Is(err, errCarrierUnavailable) {
+		t.Errorf("Expected the last handler error to be wrapped but got %v", err)
+	}
+}
+
+func TestRetryPolicyLogsAttemptsOfFailedTransition(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	handler := &flakyHandler{failures: 5, err: MarkRetryable(errCarrierUnavailable)}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		WithRetryPolicy(FromContextHandler(handler), RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	core, logs := observer.New(zap.DebugLevel)
+	so := NewStateObject(map[string]interface{}{}, sm, zap.New(core))
+	if err := so.TransitionTo(sm, SIMActivated); err == nil {
+		t.Fatalf("Expected transition to fail")
+	}
+
+	entries := logs.FilterMessage("log_transition").All()
+	if len(entries) != 1 {
+		t.Fatalf("Expected the failed transition to be logged once but got %d entries", len(entries))
+	}
+	logged := entries[0].ContextMap()["transition"].(string)
+	if strings.Count(logged, "attempt:") != 3 || !strings.Contains(logged, "error: handler") {
+		t.Errorf("Expected the attempts and the error in the log but got %s", logged)
+	}
+}
+
+func TestRetryPolicySkipsPermanentErrors(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	handler := &flakyHandler{failures: 5, err: errCarrierUnavailable}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		WithRetryPolicy(FromContextHandler(handler), RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err == nil {
+		t.Fatalf("Expected transition to fail")
+	}
+	if handler.calls != 1 {
+		t.Errorf("Expected an error that is not retryable to be tried once but got %d calls", handler.calls)
+	}
+}
+
+func TestRetryPolicyClassifier(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	handler := &flakyHandler{failures: 1, err: errCarrierUnavailable}
+	transition := sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		WithRetryPolicy(FromContextHandler(handler), RetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+			Retryable: func(err error) bool {
+				return errors.Is(err, errCarrierUnavailable)
+			},
+		}))
+
+	tc := &TransitionContext{Machine: sm, Object: &StateObject{}, Log: &StateTransitionLog{}}
+	contextHandler := AdaptHandler(transition.Handlers[0])
+	if err := tc.handle(context.Background(), transition.Handlers[0], contextHandler); err != nil {
+		t.Fatalf("Expected the second attempt to succeed but got %v", err)
+	}
+	if len(tc.Log.Attempts) != 2 {
+		t.Fatalf("Expected 2 attempts in the transition log but got %d", len(tc.Log.Attempts))
+	}
+	if tc.Log.Attempts[0].Err == nil || tc.Log.Attempts[1].Err != nil {
+		t.Errorf("Expected the attempts to record the handler errors")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+	for i, wait := range expected {
+		if backoff := policy.backoff(i + 1); backoff != wait {
+			t.Errorf("Expected backoff %v after attempt %d but got %v", wait, i+1, backoff)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if backoff := policy.backoff(1); backoff < 50*time.Millisecond || backoff > 150*time.Millisecond {
+			t.Fatalf("Expected jittered backoff within 50%% but got %v", backoff)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	if IsRetryable(errCarrierUnavailable) {
+		t.Errorf("Plain errors should not be retryable")
+	}
+	if !IsRetryable(MarkRetryable(errCarrierUnavailable)) {
+		t.Errorf("Marked errors should be retryable")
+	}
+	if MarkRetryable(nil) != nil {
+		t.Errorf("Marking nil should return nil")
+	}
+}
@@ -0,0 +1,103 @@
+package statemachine
+
+import (
+	"errors"
+	"strings"
+)
+
+// RollbackPolicy decides what happens when a handler can't be rolled back
+// after a failed transition.
+type RollbackPolicy int
+
+const (
+	// RollbackEscalate stops rolling back at the first failure and moves
+	// the object to ManualReview.
+	RollbackEscalate RollbackPolicy = iota
+	// RollbackStopOnFailure stops rolling back at the first failure and
+	// leaves the state of the object unchanged.
+	RollbackStopOnFailure
+	// RollbackBestEffort keeps rolling back the remaining handlers after a
+	// failure and reports every failure, leaving the state unchanged.
+	RollbackBestEffort
+)
+
+// WithRollbackPolicy sets how this transition handles rollback failures.
+func (t *StateTransition) WithRollbackPolicy(policy RollbackPolicy) *StateTransition {
+	t.RollbackPolicy = policy
+	return t
+}
+
+// Compensating is implemented by handlers that may leave partial work
+// behind when they fail. When CompensateOnFailure returns true, the failed
+// handler is rolled back too, before the handlers that ran before it.
+type Compensating interface {
+	CompensateOnFailure() bool
+}
+
+// CompensateOnFailure makes the handler roll back its own partial work when
+// it fails. The handler keeps its name, so it can still take the place of a
+// default slot.
+func CompensateOnFailure(h Handler) Handler {
+	return &compensatingHandler{Handler: h}
+}
+
+type compensatingHandler struct {
+	Handler
+}
+
+func (h *compensatingHandler) Name() string {
+	return handlerSlot(h.Handler)
+}
+
+func (h *compensatingHandler) CompensateOnFailure() bool {
+	return true
+}
+
+func (h *compensatingHandler) Unwrap() Handler {
+	return h.Handler
+}
+
+func (h *compensatingHandler) contextHandler() ContextHandler {
+	return AdaptHandler(h.Handler)
+}
+
+// compensatesOnFailure reports whether the handler, or a handler it wraps,
+// compensates its own partial work.
+func compensatesOnFailure(h Handler) bool {
+	for h != nil {
+		if compensating, ok := h.(Compensating); ok && compensating.CompensateOnFailure() {
+			return true
+		}
+		h = unwrapHandler(h)
+	}
+	return false
+}
+
+// unwrapHandler returns the handler wrapped by a handler option such as
+// WithRetryPolicy, or nil.
+func unwrapHandler(h Handler) Handler {
+	if wrapper, ok := h.(interface{ Unwrap() Handler }); ok {
+		return wrapper.Unwrap()
+	}
+	return nil
+}
+
+// rollbackFailures reports several handlers that could not be rolled back.
+type rollbackFailures []error
+
+func (e rollbackFailures) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e rollbackFailures) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package statemachine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCompensateOnFailure(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	before := &mockContextHandler{}
+	failing := &mockContextHandler{err: errCarrierUnavailable}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		FromContextHandler(before),
+		CompensateOnFailure(FromContextHandler(failing)),
+	)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); !errors.Is(err, errCarrierUnavailable) {
+		t.Fatalf("Expected handler error but got %v", err)
+	}
+	if failing.rolledBack != 1 {
+		t.Errorf("Expected the failing handler to compensate its own work")
+	}
+	if before.rolledBack != 1 {
+		t.Errorf("Expected the executed handler to be rolled back")
+	}
+}
+
+func TestCompensateOnFailureThroughWrappers(t *testing.T) {
+	sm := newTestStateMachine()
+	handler := WithRetryPolicy(CompensateOnFailure(&MockHandler{}), RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+	breaker := sm.CircuitBreaker("carrier", CircuitBreakerSettings{}).Wrap(handler)
+	if !compensatesOnFailure(breaker) {
+		t.Errorf("Expected compensation to be found through the wrappers")
+	}
+	if _, ok := retryPolicyOf(breaker); !ok {
+		t.Errorf("Expected the retry policy to be found through the circuit breaker")
+	}
+	if compensatesOnFailure(&MockHandler{}) {
+		t.Errorf("Plain handlers should not compensate on failure")
+	}
+}
+
+func registerRollbackScenario(sm *StateMachine, policy RollbackPolicy) (*mockContextHandler, *mockContextHandler) {
+	sm.SetHandlerConfig(HandlerConfig{})
+	first := &mockContextHandler{}
+	second := &mockContextHandler{rollbackErr: errors.New("cannot refund")}
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		FromContextHandler(first),
+		FromContextHandler(second),
+		FromContextHandler(&mockContextHandler{err: errCarrierUnavailable}),
+	).WithRollbackPolicy(policy)
+	return first, second
+}
+
+func TestRollbackPolicyEscalate(t *testing.T) {
+	sm := newTestStateMachine()
+	first, _ := registerRollbackScenario(sm, RollbackEscalate)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	var handlerErr *HandlerError
+	if !errors.As(err, &handlerErr) || !handlerErr.ManualReview {
+		t.Fatalf("Expected the rollback failure to be escalated but got %v", err)
+	}
+	if so.State != ManualReview {
+		t.Errorf("Expected state to be %s but got %s", ManualReview, so.State)
+	}
+	if first.rolledBack != 0 {
+		t.Errorf("Expected rollback to stop at the first failure")
+	}
+}
+
+func TestRollbackPolicyStopOnFailure(t *testing.T) {
+	sm := newTestStateMachine()
+	first, _ := registerRollbackScenario(sm, RollbackStopOnFailure)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	if !errors.Is(err, ErrRollbackFailed) {
+		t.Fatalf("Expected the rollback failure to be reported but got %v", err)
+	}
+	if so.State != SIMNotActivated {
+		t.Errorf("Expected state to stay %s but got %s", SIMNotActivated, so.State)
+	}
+	if first.rolledBack != 0 {
+		t.Errorf("Expected rollback to stop at the first failure")
+	}
+}
+
+func TestRollbackPolicyBestEffort(t *testing.T) {
+	sm := newTestStateMachine()
+	first, second := registerRollbackScenario(sm, RollbackBestEffort)
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	err := so.TransitionTo(sm, SIMActivated)
+	if !errors.Is(err, ErrRollbackFailed) {
+		t.Fatalf("Expected the rollback failure to be reported but got %v", err)
+	}
+	if so.State != SIMNotActivated {
+		t.Errorf("Expected state to stay %s but got %s", SIMNotActivated, so.State)
+	}
+	if second.rolledBack != 1 || first.rolledBack != 1 {
+		t.Errorf("Expected rollback to continue after the failure")
+	}
+}
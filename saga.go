@@ -0,0 +1,339 @@
+package statemachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// SagaStatus is the progress of a saga as a whole.
+type SagaStatus string
+
+const (
+	SagaRunning      SagaStatus = "running"
+	SagaCompleted    SagaStatus = "completed"
+	SagaCompensating SagaStatus = "compensating"
+	SagaCompensated  SagaStatus = "compensated"
+	// SagaFailed means a compensating transition failed and the saga needs
+	// manual intervention.
+	SagaFailed SagaStatus = "failed"
+)
+
+// SagaStep transitions one StateObject as part of a saga.
+type SagaStep struct {
+	// Name identifies the step in the persisted progress. It must be unique
+	// within the saga and stay the same when the saga is resumed.
+	Name   string
+	Object *StateObject
+	To     string
+	// Compensate is the state the object is transitioned to when a later
+	// step fails. When empty, it goes back to the state it had before the
+	// step.
+	Compensate string
+}
+
+// SagaProgress is the persisted progress of a saga.
+type SagaProgress struct {
+	ID        string           `json:"id"`
+	Status    SagaStatus       `json:"status"`
+	Steps     []SagaStepRecord `json:"steps"`
+	FailedAt  string           `json:"failedAt,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	CompError string           `json:"compensationError,omitempty"`
+}
+
+// SagaStepRecord is the persisted progress of one step.
+type SagaStepRecord struct {
+	Name        string `json:"name"`
+	From        string `json:"from"`
+	Completed   bool   `json:"completed"`
+	Compensated bool   `json:"compensated"`
+}
+
+// SagaStore persists the progress of sagas so they can be resumed after a
+// crash.
+type SagaStore interface {
+	// Load returns the progress of the saga, or ErrSagaNotFound.
+	Load(ctx context.Context, id string) (*SagaProgress, error)
+	Save(ctx context.Context, progress *SagaProgress) error
+}
+
+// ErrSagaNotFound is returned by a SagaStore for a saga it has no progress
+// for.
+var ErrSagaNotFound = errors.New("saga not found")
+
+// SagaError is returned by a saga that could not complete. It names the
+// step that failed and the outcome of compensating the completed steps.
+type SagaError struct {
+	SagaID       string
+	Step         string
+	Err          error
+	Compensation error
+}
+
+func (e *SagaError) Error() string {
+	msg := fmt.Sprintf("saga %s failed at step %s: %v", e.SagaID, e.Step, e.Err)
+	if e.Compensation != nil {
+		msg += fmt.Sprintf("; compensation failed: %v", e.Compensation)
+	}
+	return msg
+}
+
+func (e *SagaError) Unwrap() error {
+	return e.Err
+}
+
+// Saga transitions several StateObjects as steps. When a step fails, the
+// objects of the completed steps are transitioned back by compensating
+// transitions in reverse order. Each object is committed after its step and
+// the progress is saved, so a saga interrupted by a crash can be resumed by
+// running it again with the same ID and steps.
+type Saga struct {
+	ID    string
+	Steps []SagaStep
+
+	sm    *StateMachine
+	store SagaStore
+}
+
+// NewSaga creates a saga whose progress is stored in the Redis instance of
+// the StateMachine.
+func (sm *StateMachine) NewSaga(id string, steps ...SagaStep) *Saga {
+	return &Saga{
+		ID:    id,
+		Steps: steps,
+		sm:    sm,
+		store: NewRedisSagaStore(sm.redisClient),
+	}
+}
+
+// WithStore makes the saga persist its progress in the given store.
+func (s *Saga) WithStore(store SagaStore) *Saga {
+	s.store = store
+	return s
+}
+
+// Run executes the saga, or resumes it from its persisted progress. Steps
+// that already completed are skipped.
+func (s *Saga) Run(ctx context.Context) error {
+	progress, err := s.store.Load(ctx, s.ID)
+	if errors.Is(err, ErrSagaNotFound) {
+		progress = &SagaProgress{ID: s.ID, Status: SagaRunning}
+	} else if err != nil {
+		return err
+	}
+
+	switch progress.Status {
+	case SagaCompleted:
+		return nil
+	case SagaCompensated, SagaFailed:
+		return s.failure(progress)
+	case SagaCompensating:
+		return s.compensate(ctx, progress)
+	}
+
+	for _, step := range s.Steps {
+		record := progress.record(step.Name)
+		if record.Completed {
+			continue
+		}
+		// A step with a saved From whose object is already in the target
+		// state completed, but the saga stopped before saving it
+		if record.From == "" || step.Object.State != step.To {
+			record.From = step.Object.State
+			if err := s.store.Save(ctx, progress); err != nil {
+				return err
+			}
+			step.Object.EventID = s.eventID(step, "forward")
+			stepErr := step.Object.TransitionToContext(ctx, s.sm, step.To)
+			if errors.Is(stepErr, ErrAlreadyProcessed) {
+				// The event of the step was processed before the saga
+				// stopped, so the object is in the target state
+				step.Object.State = step.To
+				stepErr = nil
+			}
+			if stepErr != nil {
+				return s.abort(ctx, progress, step, stepErr)
+			}
+		}
+		record.Completed = true
+		if err := step.Object.CommitToDisk(); err != nil {
+			return s.abort(ctx, progress, step, err)
+		}
+		if err := s.store.Save(ctx, progress); err != nil {
+			return err
+		}
+	}
+
+	progress.Status = SagaCompleted
+	return s.store.Save(ctx, progress)
+}
+
+// abort records the failure of the step and compensates the completed
+// steps.
+func (s *Saga) abort(ctx context.Context, progress *SagaProgress, step SagaStep, stepErr error) error {
+	s.sm.LogErr(fmt.Errorf("Saga %s failed at step %s: %w", s.ID, step.Name, stepErr))
+	progress.Status = SagaCompensating
+	progress.FailedAt = step.Name
+	progress.Error = stepErr.Error()
+	if err := s.store.Save(ctx, progress); err != nil {
+		return err
+	}
+	err := s.compensate(ctx, progress)
+	var sagaErr *SagaError
+	if errors.As(err, &sagaErr) {
+		sagaErr.Err = stepErr
+	}
+	return err
+}
+
+// eventID returns the event ID of the forward or compensating transition
+// of a step. Each gets its own, so the dedupe handler doesn't take a
+// compensation for the already processed forward transition.
+func (s *Saga) eventID(step SagaStep, direction string) string {
+	return s.ID + "/" + step.Name + "/" + direction
+}
+
+// compensate transitions the objects of the completed steps back in reverse
+// order and returns the error the saga failed with.
+func (s *Saga) compensate(ctx context.Context, progress *SagaProgress) error {
+	for i := len(s.Steps) - 1; i >= 0; i-- {
+		step := s.Steps[i]
+		record := progress.record(step.Name)
+		if !record.Completed || record.Compensated {
+			continue
+		}
+		target := step.Compensate
+		if target == "" {
+			target = record.From
+		}
+		if step.Object.State != target {
+			step.Object.EventID = s.eventID(step, "compensate")
+			err := step.Object.TransitionToContext(ctx, s.sm, target)
+			if errors.Is(err, ErrAlreadyProcessed) {
+				// The compensation was processed before the saga stopped,
+				// so the object is in the target state
+				step.Object.State = target
+				err = nil
+			}
+			if err == nil {
+				err = step.Object.CommitToDisk()
+			}
+			if err != nil {
+				s.sm.LogErr(fmt.Errorf("Saga %s failed to compensate step %s: %w", s.ID, step.Name, err))
+				progress.Status = SagaFailed
+				progress.CompError = fmt.Sprintf("step %s: %v", step.Name, err)
+				if err := s.store.Save(ctx, progress); err != nil {
+					return err
+				}
+				return s.failure(progress)
+			}
+		}
+		record.Compensated = true
+		if err := s.store.Save(ctx, progress); err != nil {
+			return err
+		}
+	}
+
+	progress.Status = SagaCompensated
+	if err := s.store.Save(ctx, progress); err != nil {
+		return err
+	}
+	return s.failure(progress)
+}
+
+func (s *Saga) failure(progress *SagaProgress) error {
+	sagaErr := &SagaError{
+		SagaID: s.ID,
+		Step:   progress.FailedAt,
+		Err:    errors.New(progress.Error),
+	}
+	if progress.CompError != "" {
+		sagaErr.Compensation = errors.New(progress.CompError)
+	}
+	return sagaErr
+}
+
+// record returns the record of the named step, adding it when missing.
+func (p *SagaProgress) record(name string) *SagaStepRecord {
+	for i := range p.Steps {
+		if p.Steps[i].Name == name {
+			return &p.Steps[i]
+		}
+	}
+	p.Steps = append(p.Steps, SagaStepRecord{Name: name})
+	return &p.Steps[len(p.Steps)-1]
+}
+
+// NewRedisSagaStore returns a SagaStore that keeps the progress of every
+// saga under a "saga:" prefixed key.
+func NewRedisSagaStore(client *redis.Client) SagaStore {
+	return redisSagaStore{client: client}
+}
+
+type redisSagaStore struct {
+	client *redis.Client
+}
+
+func (s redisSagaStore) Load(ctx context.Context, id string) (*SagaProgress, error) {
+	data, err := s.client.Get(ctx, "saga:"+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSagaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var progress SagaProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+func (s redisSagaStore) Save(ctx context.Context, progress *SagaProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, "saga:"+progress.ID, data, 0).Err()
+}
+
+// NewMemorySagaStore returns a SagaStore that keeps progress in memory, for
+// tests and single process use.
+func NewMemorySagaStore() SagaStore {
+	return &memorySagaStore{sagas: make(map[string][]byte)}
+}
+
+type memorySagaStore struct {
+	mu    sync.Mutex
+	sagas map[string][]byte
+}
+
+func (s *memorySagaStore) Load(ctx context.Context, id string) (*SagaProgress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.sagas[id]
+	if !ok {
+		return nil, ErrSagaNotFound
+	}
+	var progress SagaProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+func (s *memorySagaStore) Save(ctx context.Context, progress *SagaProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sagas[progress.ID] = data
+	return nil
+}
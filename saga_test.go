@@ -0,0 +1,248 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+const (
+	NumberReserved = "NumberReserved"
+	NumberPorted   = "NumberPorted"
+)
+
+type portingFixture struct {
+	sm      *StateMachine
+	sim     *StateObject
+	number  *StateObject
+	billing *StateObject
+	store   SagaStore
+	// committed is the state each object was last committed in
+	committed map[*StateObject]string
+}
+
+func newPortingFixture(t *testing.T, billingErr error) *portingFixture {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	return newPortingFixtureFor(t, sm, billingErr)
+}
+
+func newPortingFixtureFor(t *testing.T, sm *StateMachine, billingErr error) *portingFixture {
+	sm.RegisterTransition(SIMNotActivated, SIMActivated)
+	sm.RegisterTransition(SIMActivated, SIMNotActivated)
+	sm.RegisterTransition(NumberReserved, NumberPorted)
+	sm.RegisterTransition(NumberPorted, NumberReserved)
+	sm.RegisterTransition(BillingFailed, BillingPaid, FromContextHandler(&mockContextHandler{err: billingErr}))
+
+	logger := zaptest.NewLogger(t)
+	f := &portingFixture{
+		sm:      sm,
+		sim:     NewStateObject(map[string]interface{}{}, sm, logger),
+		number:  NewStateObject(map[string]interface{}{}, sm, logger),
+		billing: NewStateObject(map[string]interface{}{}, sm, logger),
+		store:   NewMemorySagaStore(),
+
+		committed: make(map[*StateObject]string),
+	}
+	f.number.State = NumberReserved
+	f.billing.State = BillingFailed
+	for _, so := range []*StateObject{f.sim, f.number, f.billing} {
+		so := so
+		so.CommitFunc = func() error {
+			f.committed[so] = so.State
+			return nil
+		}
+	}
+	return f
+}
+
+// memoryProcessed stands in for the Redis backed dedupe and markprocessed
+// handlers.
+type memoryProcessed map[string]bool
+
+func (m memoryProcessed) install(sm *StateMachine) {
+	sm.ReplaceHandler(SlotDedupe, NewHandler(SlotDedupe, func(ctx context.Context, tc *TransitionContext) error {
+		if m[tc.Object.EventID] {
+			return ErrAlreadyProcessed
+		}
+		return nil
+	}, nil))
+	sm.ReplaceHandler(SlotMarkProcessed, NewHandler(SlotMarkProcessed, func(ctx context.Context, tc *TransitionContext) error {
+		m[tc.Object.EventID] = true
+		return nil
+	}, nil))
+}
+
+func (f *portingFixture) saga() *Saga {
+	return f.sm.NewSaga("port-1234567890",
+		SagaStep{Name: "activate-sim", Object: f.sim, To: SIMActivated},
+		SagaStep{Name: "port-number", Object: f.number, To: NumberPorted},
+		SagaStep{Name: "charge", Object: f.billing, To: BillingPaid},
+	).WithStore(f.store)
+}
+
+func TestSagaCompletes(t *testing.T) {
+	f := newPortingFixture(t, nil)
+	if err := f.saga().Run(context.Background()); err != nil {
+		t.Fatalf("Saga failed: %v", err)
+	}
+	if f.sim.State != SIMActivated || f.number.State != NumberPorted || f.billing.State != BillingPaid {
+		t.Errorf("Expected every object to be transitioned")
+	}
+	progress, err := f.store.Load(context.Background(), "port-1234567890")
+	if err != nil {
+		t.Fatalf("Failed to load saga progress: %v", err)
+	}
+	if progress.Status != SagaCompleted {
+		t.Errorf("Expected saga status %s but got %s", SagaCompleted, progress.Status)
+	}
+}
+
+func TestSagaCompensatesCompletedSteps(t *testing.T) {
+	f := newPortingFixture(t, errCarrierUnavailable)
+	err := f.saga().Run(context.Background())
+
+	var sagaErr *SagaError
+	if !errors.As(err, &sagaErr) {
+		t.Fatalf("Expected a *SagaError but got %v", err)
+	}
+	if sagaErr.Step != "charge" || !errors.Is(err, errCarrierUnavailable) {
+		t.Errorf("Expected the charge step to be reported but got %v", err)
+	}
+	if sagaErr.Compensation != nil {
+		t.Errorf("Expected compensation to succeed but got %v", sagaErr.Compensation)
+	}
+	if f.sim.State != SIMNotActivated || f.number.State != NumberReserved {
+		t.Errorf("Expected completed steps to be compensated but got %s and %s", f.sim.State, f.number.State)
+	}
+
+	progress, _ := f.store.Load(context.Background(), "port-1234567890")
+	if progress.Status != SagaCompensated {
+		t.Errorf("Expected saga status %s but got %s", SagaCompensated, progress.Status)
+	}
+
+	// Running it again reports the same outcome without repeating any step
+	if err := f.saga().Run(context.Background()); !errors.As(err, &sagaErr) || sagaErr.Step != "charge" {
+		t.Errorf("Expected the persisted failure to be reported but got %v", err)
+	}
+}
+
+func TestSagaResumesAfterCrash(t *testing.T) {
+	f := newPortingFixture(t, nil)
+	activations := &countingHandler{}
+	f.sm.RegisterTransition(SIMNotActivated, SIMActivated, activations)
+
+	// The saga stopped after porting the number but before saving that step
+	f.sim.State = SIMActivated
+	f.number.State = NumberPorted
+	f.store.Save(context.Background(), &SagaProgress{
+		ID:     "port-1234567890",
+		Status: SagaRunning,
+		Steps: []SagaStepRecord{
+			{Name: "activate-sim", From: SIMNotActivated, Completed: true},
+			{Name: "port-number", From: NumberReserved},
+		},
+	})
+
+	if err := f.saga().Run(context.Background()); err != nil {
+		t.Fatalf("Resumed saga failed: %v", err)
+	}
+	if activations.calls != 0 {
+		t.Errorf("Expected completed steps to be skipped")
+	}
+	if f.billing.State != BillingPaid {
+		t.Errorf("Expected the remaining step to run")
+	}
+}
+
+func TestSagaCompensationFailure(t *testing.T) {
+	f := newPortingFixture(t, errCarrierUnavailable)
+	// No transition back from NumberPorted, so it can't be compensated
+	delete(f.sm.transitions, NumberPorted+"->"+NumberReserved)
+
+	err := f.saga().Run(context.Background())
+	var sagaErr *SagaError
+	if !errors.As(err, &sagaErr) || sagaErr.Compensation == nil {
+		t.Fatalf("Expected the compensation failure to be reported but got %v", err)
+	}
+	progress, _ := f.store.Load(context.Background(), "port-1234567890")
+	if progress.Status != SagaFailed {
+		t.Errorf("Expected saga status %s but got %s", SagaFailed, progress.Status)
+	}
+}
+
+func TestSagaCompensatesWithDefaultHandlers(t *testing.T) {
+	sm := newTestStateMachine()
+	processed := memoryProcessed{}
+	processed.install(sm)
+	f := newPortingFixtureFor(t, sm, errCarrierUnavailable)
+
+	err := f.saga().Run(context.Background())
+	var sagaErr *SagaError
+	if !errors.As(err, &sagaErr) || sagaErr.Compensation != nil {
+		t.Fatalf("Expected the saga to be compensated but got %v", err)
+	}
+	if f.sim.State != SIMNotActivated || f.number.State != NumberReserved {
+		t.Errorf("Expected completed steps to be compensated but got %s and %s", f.sim.State, f.number.State)
+	}
+	if f.committed[f.sim] != SIMNotActivated || f.committed[f.number] != NumberReserved {
+		t.Errorf("Expected the compensated objects to be committed but got %v", f.committed)
+	}
+	if !processed["port-1234567890/activate-sim/forward"] || !processed["port-1234567890/activate-sim/compensate"] {
+		t.Errorf("Expected forward and compensating transitions to have their own event IDs but got %v", processed)
+	}
+}
+
+func TestSagaResumesCompensationAfterCrash(t *testing.T) {
+	sm := newTestStateMachine()
+	// The compensation of the number was processed, but the saga stopped
+	// before committing the number and saving that step
+	processed := memoryProcessed{
+		"port-1234567890/activate-sim/forward":   true,
+		"port-1234567890/port-number/forward":    true,
+		"port-1234567890/port-number/compensate": true,
+	}
+	processed.install(sm)
+	f := newPortingFixtureFor(t, sm, errCarrierUnavailable)
+	f.sim.State = SIMActivated
+	f.number.State = NumberPorted
+	f.store.Save(context.Background(), &SagaProgress{
+		ID:       "port-1234567890",
+		Status:   SagaCompensating,
+		FailedAt: "charge",
+		Error:    errCarrierUnavailable.Error(),
+		Steps: []SagaStepRecord{
+			{Name: "activate-sim", From: SIMNotActivated, Completed: true},
+			{Name: "port-number", From: NumberReserved, Completed: true},
+			{Name: "charge", From: BillingFailed},
+		},
+	})
+
+	err := f.saga().Run(context.Background())
+	var sagaErr *SagaError
+	if !errors.As(err, &sagaErr) || sagaErr.Compensation != nil {
+		t.Fatalf("Expected the saga to be compensated but got %v", err)
+	}
+	if f.number.State != NumberReserved || f.committed[f.number] != NumberReserved {
+		t.Errorf("Expected the number to be committed as %s but got %s", NumberReserved, f.committed[f.number])
+	}
+	if f.sim.State != SIMNotActivated {
+		t.Errorf("Expected the SIM to be compensated but got %s", f.sim.State)
+	}
+	progress, _ := f.store.Load(context.Background(), "port-1234567890")
+	if progress.Status != SagaCompensated {
+		t.Errorf("Expected saga status %s but got %s", SagaCompensated, progress.Status)
+	}
+}
+
+func TestSagaCommitsEachStep(t *testing.T) {
+	f := newPortingFixture(t, nil)
+	if err := f.saga().Run(context.Background()); err != nil {
+		t.Fatalf("Saga failed: %v", err)
+	}
+	if f.committed[f.sim] != SIMActivated || f.committed[f.number] != NumberPorted || f.committed[f.billing] != BillingPaid {
+		t.Errorf("Expected every object to be committed after its step but got %v", f.committed)
+	}
+}
@@ -0,0 +1,422 @@
+package statemachine
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const scxmlNamespace = "http://www.w3.org/2005/07/scxml"
+
+// machineNamespace holds the SCXML attributes for what SCXML has no
+// equivalent of: the handler chain and timeout of a transition.
+const machineNamespace = "https://github.com/hibrid/statemachine"
+
+// SCXML exports the machine as a W3C SCXML document. Transitions without an
+// event are the ones run with TransitionTo, guards are written as the cond
+// of their transition, and internal transitions are targetless. Wildcards
+// and source sets are written once for every state they apply to.
+func (sm *StateMachine) SCXML() []byte {
+	def := sm.Definition()
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<scxml xmlns="` + scxmlNamespace + `" xmlns:sm="` + machineNamespace + `" version="1.0"`)
+	if def.Initial != "" {
+		writeAttr(&b, "initial", def.Initial)
+	}
+	b.WriteString(">\n")
+
+	for _, state := range def.States {
+		transitions := transitionsFrom(def, state.Name)
+		element := "state"
+		if state.Final && len(transitions) == 0 {
+			element = "final"
+		}
+		b.WriteString("  <" + element)
+		writeAttr(&b, "id", state.Name)
+		if len(transitions) == 0 {
+			b.WriteString("/>\n")
+			continue
+		}
+		b.WriteString(">\n")
+		for _, t := range transitions {
+			b.WriteString("    <transition")
+			writeAttr(&b, "event", t.Event)
+			writeAttr(&b, "cond", t.Guard)
+			if t.Internal {
+				writeAttr(&b, "type", "internal")
+			} else {
+				writeAttr(&b, "target", t.To)
+			}
+			writeAttr(&b, "sm:handlers", strings.Join(t.Handlers, " "))
+			if t.Timeout > 0 {
+				writeAttr(&b, "sm:timeout", time.Duration(t.Timeout).String())
+			}
+			b.WriteString("/>\n")
+		}
+		b.WriteString("  </" + element + ">\n")
+	}
+	b.WriteString("</scxml>\n")
+	return b.Bytes()
+}
+
+// transitionsFrom returns the transitions of the definition that leave the
+// state, following the precedence of lookupTransition for wildcards without
+// an event. Fire runs wildcards with an event whatever else leaves the state.
+func transitionsFrom(def *Definition, state string) []TransitionDefinition {
+	var exact, shared, wildcards []TransitionDefinition
+	covered := make(map[string]bool)
+	for _, t := range def.Transitions {
+		switch {
+		case t.Internal:
+			if t.From == state || t.From == AnyState {
+				exact = append(exact, t)
+			}
+		case t.From == state:
+			exact = append(exact, t)
+			covered[t.To] = true
+		case containsState(t.Sources, state):
+			shared = append(shared, t)
+			covered[t.To] = true
+		case t.From == AnyState && t.To != state && !containsState(t.Except, state):
+			wildcards = append(wildcards, t)
+		}
+	}
+	transitions := append(exact, shared...)
+	for _, t := range wildcards {
+		if t.Event != "" || !covered[t.To] {
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions
+}
+
+func writeAttr(b *bytes.Buffer, name, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteString(" " + name + `="`)
+	xml.EscapeText(b, []byte(value))
+	b.WriteString(`"`)
+}
+
+type scxmlDocument struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/07/scxml scxml"`
+	Name     string      `xml:"name,attr"`
+	Initial  string      `xml:"initial,attr"`
+	Children []scxmlNode `xml:",any"`
+}
+
+type scxmlNode struct {
+	XMLName     xml.Name
+	ID          string            `xml:"id,attr"`
+	Initial     string            `xml:"initial,attr"`
+	Transitions []scxmlTransition `xml:"transition"`
+	Children    []scxmlNode       `xml:",any"`
+}
+
+type scxmlTransition struct {
+	Event    string `xml:"event,attr"`
+	Cond     string `xml:"cond,attr"`
+	Target   string `xml:"target,attr"`
+	Handlers string `xml:"https://github.com/hibrid/statemachine handlers,attr"`
+	Timeout  string `xml:"https://github.com/hibrid/statemachine timeout,attr"`
+}
+
+// states returns the state, parallel and final children of the node,
+// skipping executable content and data models.
+func (n scxmlNode) states() []scxmlNode {
+	return scxmlStates(n.Children)
+}
+
+func scxmlStates(nodes []scxmlNode) []scxmlNode {
+	var states []scxmlNode
+	for _, node := range nodes {
+		switch node.XMLName.Local {
+		case "state", "parallel", "final":
+			states = append(states, node)
+		}
+	}
+	return states
+}
+
+// scxmlParallel is a parallel state. The machine has a single active state,
+// so a parallel state is flattened into one state per combination of the
+// states of its regions, named like "Parallel(a,b)".
+type scxmlParallel struct {
+	id      string
+	regions []scxmlRegion
+}
+
+type scxmlRegion struct {
+	id      string
+	leaves  []string
+	initial string
+}
+
+func (p *scxmlParallel) name(leaves []string) string {
+	return p.id + "(" + strings.Join(leaves, ",") + ")"
+}
+
+func (p *scxmlParallel) initialLeaves() []string {
+	leaves := make([]string, len(p.regions))
+	for i, region := range p.regions {
+		leaves[i] = region.initial
+	}
+	return leaves
+}
+
+// combinations returns every combination of the states of the regions.
+func (p *scxmlParallel) combinations() [][]string {
+	combinations := [][]string{nil}
+	for _, region := range p.regions {
+		var next [][]string
+		for _, combination := range combinations {
+			for _, leaf := range region.leaves {
+				next = append(next, append(append([]string(nil), combination...), leaf))
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+type scxmlImport struct {
+	def       *Definition
+	atomic    map[string]bool
+	parallels map[string]*scxmlParallel
+	// regionOf locates the regions and their states in their parallel
+	// state.
+	regionOf map[string]scxmlLocation
+	finals   map[string]bool
+}
+
+type scxmlLocation struct {
+	parallel *scxmlParallel
+	region   int
+	isRegion bool
+}
+
+// ParseSCXML converts a W3C SCXML document into a machine definition. It
+// supports atomic, final and parallel states, transitions, events and
+// conds naming registered guards. Regions of a parallel state may hold
+// atomic and final states; other nesting is rejected.
+func ParseSCXML(data []byte) (*Definition, error) {
+	var doc scxmlDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid SCXML: %w", err)
+	}
+
+	imp := &scxmlImport{
+		def:       &Definition{Name: doc.Name},
+		atomic:    make(map[string]bool),
+		parallels: make(map[string]*scxmlParallel),
+		regionOf:  make(map[string]scxmlLocation),
+		finals:    make(map[string]bool),
+	}
+	top := scxmlStates(doc.Children)
+	if len(top) == 0 {
+		return nil, fmt.Errorf("invalid SCXML: no states")
+	}
+	for _, node := range top {
+		if err := imp.declare(node); err != nil {
+			return nil, err
+		}
+	}
+
+	initial := doc.Initial
+	if initial == "" {
+		initial = top[0].ID
+	}
+	start, err := imp.target(initial, "")
+	if err != nil {
+		return nil, err
+	}
+	imp.def.Initial = start
+
+	for _, node := range top {
+		if err := imp.transitions(node); err != nil {
+			return nil, err
+		}
+	}
+	return imp.def, nil
+}
+
+// declare records the states of a top-level node.
+func (imp *scxmlImport) declare(node scxmlNode) error {
+	if node.ID == "" {
+		return fmt.Errorf("invalid SCXML: <%s> without id", node.XMLName.Local)
+	}
+	if node.XMLName.Local != "parallel" {
+		if len(node.states()) > 0 {
+			return fmt.Errorf("invalid SCXML: nested states in %s are not supported", node.ID)
+		}
+		imp.atomic[node.ID] = true
+		imp.finals[node.ID] = node.XMLName.Local == "final"
+		imp.def.States = append(imp.def.States, StateDefinition{Name: node.ID, Final: imp.finals[node.ID]})
+		return nil
+	}
+
+	p := &scxmlParallel{id: node.ID}
+	imp.parallels[node.ID] = p
+	for i, regionNode := range node.states() {
+		if regionNode.XMLName.Local != "state" {
+			return fmt.Errorf("invalid SCXML: region %s of %s must be a <state>", regionNode.ID, node.ID)
+		}
+		region := scxmlRegion{id: regionNode.ID}
+		imp.regionOf[regionNode.ID] = scxmlLocation{parallel: p, region: i, isRegion: true}
+		for _, leaf := range regionNode.states() {
+			if leaf.XMLName.Local == "parallel" || len(leaf.states()) > 0 {
+				return fmt.Errorf("invalid SCXML: nested states in %s are not supported", leaf.ID)
+			}
+			region.leaves = append(region.leaves, leaf.ID)
+			imp.regionOf[leaf.ID] = scxmlLocation{parallel: p, region: i}
+			imp.finals[leaf.ID] = leaf.XMLName.Local == "final"
+		}
+		if len(region.leaves) == 0 {
+			region.leaves = []string{regionNode.ID}
+		}
+		region.initial = regionNode.Initial
+		if region.initial == "" {
+			region.initial = region.leaves[0]
+		}
+		p.regions = append(p.regions, region)
+	}
+	if len(p.regions) == 0 {
+		return fmt.Errorf("invalid SCXML: parallel %s has no regions", node.ID)
+	}
+
+	for _, leaves := range p.combinations() {
+		final := true
+		for _, leaf := range leaves {
+			final = final && imp.finals[leaf]
+		}
+		imp.def.States = append(imp.def.States, StateDefinition{Name: p.name(leaves), Final: final})
+	}
+	return nil
+}
+
+// sources returns the flattened states a transition of the given element
+// leaves from.
+func (imp *scxmlImport) sources(id string) []string {
+	if imp.atomic[id] {
+		return []string{id}
+	}
+	p, ok := imp.parallels[id]
+	location, inRegion := imp.regionOf[id]
+	if inRegion {
+		p = location.parallel
+	}
+	if !ok && !inRegion {
+		return nil
+	}
+	var sources []string
+	for _, leaves := range p.combinations() {
+		if !inRegion || location.isRegion || leaves[location.region] == id {
+			sources = append(sources, p.name(leaves))
+		}
+	}
+	return sources
+}
+
+// target resolves the flattened state a transition to the given element
+// leads to from the flattened source state. Within a parallel state, only
+// the region of the target changes.
+func (imp *scxmlImport) target(id, from string) (string, error) {
+	if strings.Contains(strings.TrimSpace(id), " ") {
+		return "", fmt.Errorf("invalid SCXML: multiple targets %q are not supported", id)
+	}
+	if imp.atomic[id] {
+		return id, nil
+	}
+	if p, ok := imp.parallels[id]; ok {
+		return p.name(p.initialLeaves()), nil
+	}
+	location, ok := imp.regionOf[id]
+	if !ok {
+		return "", fmt.Errorf("invalid SCXML: unknown state %s", id)
+	}
+	p := location.parallel
+	leaves := p.initialLeaves()
+	for _, combination := range p.combinations() {
+		if p.name(combination) == from {
+			leaves = combination
+			break
+		}
+	}
+	leaves = append([]string(nil), leaves...)
+	if location.isRegion {
+		leaves[location.region] = p.regions[location.region].initial
+	} else {
+		leaves[location.region] = id
+	}
+	return p.name(leaves), nil
+}
+
+// transitions adds the transitions of the node and of its descendants.
+func (imp *scxmlImport) transitions(node scxmlNode) error {
+	for _, t := range node.Transitions {
+		if err := imp.transition(node.ID, t); err != nil {
+			return err
+		}
+	}
+	for _, child := range node.states() {
+		if err := imp.transitions(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *scxmlImport) transition(source string, t scxmlTransition) error {
+	var timeout Duration
+	if t.Timeout != "" {
+		d, err := time.ParseDuration(t.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid SCXML: transition of %s: %w", source, err)
+		}
+		timeout = Duration(d)
+	}
+	events := strings.Fields(t.Event)
+	if len(events) == 0 {
+		if t.Target == "" {
+			return fmt.Errorf("invalid SCXML: transition of %s has neither event nor target", source)
+		}
+		events = []string{""}
+	}
+
+	for _, from := range imp.sources(source) {
+		for _, event := range events {
+			td := TransitionDefinition{
+				From:     from,
+				Event:    event,
+				Guard:    t.Cond,
+				Handlers: strings.Fields(t.Handlers),
+				Timeout:  timeout,
+			}
+			if t.Target == "" {
+				td.Internal = true
+			} else {
+				to, err := imp.target(t.Target, from)
+				if err != nil {
+					return err
+				}
+				td.To = to
+			}
+			imp.def.Transitions = append(imp.def.Transitions, td)
+		}
+	}
+	return nil
+}
+
+// LoadSCXML parses the SCXML document and loads it like LoadDefinition.
+func (sm *StateMachine) LoadSCXML(data []byte) error {
+	def, err := ParseSCXML(data)
+	if err != nil {
+		return err
+	}
+	return sm.LoadDefinition(def)
+}
@@ -0,0 +1,194 @@
+package statemachine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSCXMLExport(t *testing.T) {
+	sm := newLifecycleStateMachine()
+	sm.RegisterHandler("carrierCheck", NewHandler("carrierCheck", nil, nil))
+	sm.RegisterTransition(SIMActivated, SIMRetired, sm.namedHandlers["carrierCheck"]).WithTimeout(30 * time.Second)
+	sm.RegisterInternalTransition(SIMActivated, "refresh")
+
+	scxml := string(sm.SCXML())
+	for _, want := range []string{
+		`<scxml xmlns="http://www.w3.org/2005/07/scxml" xmlns:sm="https://github.com/hibrid/statemachine" version="1.0" initial="SIMNotActivated">`,
+		`<transition event="activate" cond="hasCarrier" target="SIMActivated"/>`,
+		`<transition event="refresh" type="internal"/>`,
+		`<transition target="SIMRetired" sm:handlers="carrierCheck" sm:timeout="30s"/>`,
+		`<transition target="ManualReview"/>`,
+		`<final id="SIMRetired"/>`,
+	} {
+		if !strings.Contains(scxml, want) {
+			t.Errorf("Expected %s in\n%s", want, scxml)
+		}
+	}
+}
+
+func TestSCXMLRoundTrip(t *testing.T) {
+	handler := &mockContextHandler{}
+	exported := newLifecycleStateMachine()
+	exported.RegisterHandler("carrierCheck", FromContextHandler(handler))
+	exported.RegisterTransition(SIMActivated, SIMRetired, exported.namedHandlers["carrierCheck"])
+
+	sm := newDefinitionStateMachine(FromContextHandler(handler))
+	if err := sm.LoadSCXML(exported.SCXML()); err != nil {
+		t.Fatalf("LoadSCXML failed: %v", err)
+	}
+	if err := sm.Validate(); err != nil {
+		t.Errorf("Expected the imported machine to be valid: %v", err)
+	}
+
+	so := NewStateObject(map[string]interface{}{"carrier": "TelecomProvider"}, sm, zaptest.NewLogger(t))
+	if err := so.Fire(sm, "activate"); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	if err := so.TransitionTo(sm, SIMRetired); err != nil {
+		t.Fatalf("TransitionTo failed: %v", err)
+	}
+	if handler.handled != 1 {
+		t.Errorf("Expected the imported handler to run once but it ran %d times", handler.handled)
+	}
+}
+
+func TestSCXMLRoundTripKeepsEventsSharingAPair(t *testing.T) {
+	def, err := ParseSCXML([]byte(`<?xml version="1.0"?>
+<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" initial="SIMNotActivated">
+  <state id="SIMNotActivated">
+    <transition event="activate" target="SIMActivated"/>
+    <transition event="reactivate" target="SIMActivated"/>
+  </state>
+  <state id="SIMActivated"/>
+</scxml>`))
+	if err != nil {
+		t.Fatalf("ParseSCXML failed: %v", err)
+	}
+	sm := newDefinitionStateMachine(FromContextHandler(&mockContextHandler{}))
+	if err := sm.LoadDefinition(def); err != nil {
+		t.Fatalf("LoadDefinition failed: %v", err)
+	}
+
+	scxml := string(sm.SCXML())
+	for _, want := range []string{
+		`<transition event="activate" target="SIMActivated"/>`,
+		`<transition event="reactivate" target="SIMActivated"/>`,
+	} {
+		if !strings.Contains(scxml, want) {
+			t.Errorf("Expected %s in\n%s", want, scxml)
+		}
+	}
+}
+
+func TestSCXMLRoundTripKeepsGuardPriority(t *testing.T) {
+	always := func(tc *TransitionContext) bool { return true }
+	register := func(sm *StateMachine) {
+		sm.RegisterGuard("vip", always)
+		sm.RegisterGuard("any", always)
+	}
+	exported := newTestStateMachine()
+	exported.SetHandlerConfig(HandlerConfig{})
+	register(exported)
+	exported.RegisterEventTransition(SIMNotActivated, "pay", SIMActivated).WithGuard("vip", always)
+	exported.RegisterEventTransition(SIMNotActivated, "pay", BillingPaid).WithGuard("any", always)
+
+	loaders := map[string]func(sm *StateMachine) error{
+		"definition": func(sm *StateMachine) error { return sm.LoadDefinition(exported.Definition()) },
+		"scxml":      func(sm *StateMachine) error { return sm.LoadSCXML(exported.SCXML()) },
+	}
+	for name, load := range loaders {
+		sm := newTestStateMachine()
+		sm.SetHandlerConfig(HandlerConfig{})
+		register(sm)
+		if err := load(sm); err != nil {
+			t.Fatalf("Loading the %s failed: %v", name, err)
+		}
+		so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+		if err := so.Fire(sm, "pay"); err != nil {
+			t.Fatalf("Fire after loading the %s failed: %v", name, err)
+		}
+		if so.State != SIMActivated {
+			t.Errorf("Expected the first guarded transition to win after loading the %s but got %s", name, so.State)
+		}
+	}
+}
+
+const provisioningSCXML = `<?xml version="1.0"?>
+<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" initial="Ordered">
+  <state id="Ordered">
+    <transition event="provision" target="Provisioning"/>
+  </state>
+  <parallel id="Provisioning">
+    <state id="SIM" initial="SIMPending">
+      <state id="SIMPending">
+        <transition event="simReady" target="SIMReady"/>
+      </state>
+      <final id="SIMReady"/>
+    </state>
+    <state id="Number">
+      <state id="NumberPending">
+        <transition event="numberPorted" target="NumberPorted"/>
+      </state>
+      <final id="NumberPorted"/>
+    </state>
+    <transition event="cancel" target="Cancelled"/>
+  </parallel>
+  <final id="Cancelled"/>
+</scxml>`
+
+func TestParseSCXMLFlattensParallelStates(t *testing.T) {
+	def, err := ParseSCXML([]byte(provisioningSCXML))
+	if err != nil {
+		t.Fatalf("ParseSCXML failed: %v", err)
+	}
+
+	final := make(map[string]bool)
+	for _, state := range def.States {
+		final[state.Name] = state.Final
+	}
+	if len(def.States) != 6 {
+		t.Errorf("Expected 2 states and 4 combinations but got %+v", def.States)
+	}
+	if !final["Provisioning(SIMReady,NumberPorted)"] || final["Provisioning(SIMReady,NumberPending)"] {
+		t.Errorf("Expected only the combination of final states to be final")
+	}
+
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	if err := sm.LoadDefinition(def); err != nil {
+		t.Fatalf("LoadDefinition failed: %v", err)
+	}
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	for _, event := range []string{"provision", "numberPorted", "simReady"} {
+		if err := so.Fire(sm, event); err != nil {
+			t.Fatalf("Fire %s failed: %v", event, err)
+		}
+	}
+	if so.State != "Provisioning(SIMReady,NumberPorted)" {
+		t.Errorf("Expected both regions to complete but got %s", so.State)
+	}
+
+	so.State = "Provisioning(SIMPending,NumberPorted)"
+	if err := so.Fire(sm, "cancel"); err != nil || so.State != "Cancelled" {
+		t.Errorf("Expected cancel to leave the parallel state from any combination: %v", err)
+	}
+}
+
+func TestParseSCXMLRejectsUnsupportedDocuments(t *testing.T) {
+	tests := map[string]string{
+		"nested states":    `<scxml xmlns="http://www.w3.org/2005/07/scxml"><state id="A"><state id="B"/></state></scxml>`,
+		"unknown target":   `<scxml xmlns="http://www.w3.org/2005/07/scxml"><state id="A"><transition target="B"/></state></scxml>`,
+		"multiple targets": `<scxml xmlns="http://www.w3.org/2005/07/scxml"><state id="A"><transition target="A B"/></state><state id="B"/></scxml>`,
+		"not scxml":        `<statechart><state id="A"/></statechart>`,
+	}
+	for name, document := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseSCXML([]byte(document)); err == nil {
+				t.Errorf("Expected the document to be rejected")
+			}
+		})
+	}
+}
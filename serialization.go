@@ -0,0 +1,136 @@
+package statemachine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ContentTypeJSON is the content type of JSONSerialization.
+const ContentTypeJSON = "application/json"
+
+// ErrUnknownContentType is returned when a stored object was written with a
+// serializer that isn't registered.
+var ErrUnknownContentType = errors.New("unknown content type")
+
+// ContentTyper is implemented by a Serialization that names the format it
+// writes. Serializers without it are identified by their Go type.
+type ContentTyper interface {
+	ContentType() string
+}
+
+// EventPublisher is implemented by event emitters that publish encoded
+// events. EmitEvent hands them the payload with its content type.
+type EventPublisher interface {
+	Publish(contentType string, payload []byte) error
+}
+
+var (
+	serializationsMu sync.RWMutex
+	serializations   = map[string]Serialization{
+		ContentTypeJSON:     JSONSerialization{},
+		ContentTypeProtobuf: ProtobufSerialization{},
+		ContentTypeCapnp:    CapnpSerialization{},
+		ContentTypeMsgpack:  MsgpackSerialization{},
+		ContentTypeCBOR:     CBORSerialization{},
+	}
+)
+
+// RegisterSerialization makes objects written with the serializer readable
+// by Deserialize. A StateMachine registers its serializer when it writes an
+// object, so this is only needed to read objects written by another process.
+func RegisterSerialization(s Serialization) {
+	serializationsMu.Lock()
+	defer serializationsMu.Unlock()
+	serializations[contentTypeOf(s)] = s
+}
+
+func lookupSerialization(contentType string) (Serialization, bool) {
+	serializationsMu.RLock()
+	defer serializationsMu.RUnlock()
+	s, ok := serializations[contentType]
+	return s, ok
+}
+
+func contentTypeOf(s Serialization) string {
+	if ct, ok := s.(ContentTyper); ok {
+		return ct.ContentType()
+	}
+	return fmt.Sprintf("application/x-go-type; name=%T", s)
+}
+
+// serializer returns the configured Serialization, JSON if there is none.
+func (sm *StateMachine) serializer() Serialization {
+	if sm == nil || sm.config.Serializer == nil {
+		return JSONSerialization{}
+	}
+	return sm.config.Serializer
+}
+
+// blobMarker starts a stored object. It is followed by the content type and
+// a newline, then the serialized object. Objects stored before the marker
+// existed start with '{' and are read as JSON.
+const blobMarker = 0
+
+// encode serializes the object with the configured serializer and prefixes
+// it with its content type, as it is stored.
+func (sm *StateMachine) encode(so *StateObject) ([]byte, error) {
+	serializer := sm.serializer()
+	payload, err := serializer.Serialize(so)
+	if err != nil {
+		return nil, err
+	}
+	contentType := contentTypeOf(serializer)
+	if _, ok := lookupSerialization(contentType); !ok {
+		RegisterSerialization(serializer)
+	}
+	blob := make([]byte, 0, len(contentType)+len(payload)+2)
+	blob = append(blob, blobMarker)
+	blob = append(blob, contentType...)
+	blob = append(blob, '\n')
+	return append(blob, payload...), nil
+}
+
+// splitBlob returns the content type and the serialized object of a stored
+// object.
+func splitBlob(data []byte) (string, []byte, error) {
+	if len(data) == 0 || data[0] != blobMarker {
+		return ContentTypeJSON, data, nil
+	}
+	end := bytes.IndexByte(data, '\n')
+	if end < 0 {
+		return "", nil, fmt.Errorf("%w: unterminated content type", ErrUnknownContentType)
+	}
+	return string(data[1:end]), data[end+1:], nil
+}
+
+// decodeBlob finds the serializer a stored object was written with.
+func decodeBlob(data []byte) (Serialization, []byte, error) {
+	contentType, payload, err := splitBlob(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	serializer, ok := lookupSerialization(contentType)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownContentType, contentType)
+	}
+	return serializer, payload, nil
+}
+
+// binaryObject is the StateObject as written by MsgpackSerialization and
+// CBORSerialization. Data holds the typed data of a TypedStateObject.
+type binaryObject struct {
+	ID      string      `json:"id,omitempty"`
+	Data    interface{} `json:"data"`
+	State   string      `json:"state"`
+	EventID string      `json:"eventID"`
+}
+
+func newBinaryObject(so *StateObject) binaryObject {
+	obj := binaryObject{ID: so.ID, Data: so.Data, State: so.State, EventID: so.EventID}
+	if so.typed != nil {
+		obj.Data = so.typed
+	}
+	return obj
+}
@@ -0,0 +1,598 @@
+// Code generated by capnpc-go. DO NOT EDIT.
+
+package capnpmodels
+
+import (
+	capnp "capnproto.org/go/capnp/v3"
+	text "capnproto.org/go/capnp/v3/encoding/text"
+	schemas "capnproto.org/go/capnp/v3/schemas"
+	math "math"
+)
+
+type StateObject capnp.Struct
+
+// StateObject_TypeID is the unique identifier for the type StateObject.
+const StateObject_TypeID = 0xdcf2ad88a4d507e4
+
+func NewStateObject(s *capnp.Segment) (StateObject, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 4})
+	return StateObject(st), err
+}
+
+func NewRootStateObject(s *capnp.Segment) (StateObject, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 4})
+	return StateObject(st), err
+}
+
+func ReadRootStateObject(msg *capnp.Message) (StateObject, error) {
+	root, err := msg.Root()
+	return StateObject(root.Struct()), err
+}
+
+func (s StateObject) String() string {
+	str, _ := text.Marshal(0xdcf2ad88a4d507e4, capnp.Struct(s))
+	return str
+}
+
+func (s StateObject) EncodeAsPtr(seg *capnp.Segment) capnp.Ptr {
+	return capnp.Struct(s).EncodeAsPtr(seg)
+}
+
+func (StateObject) DecodeFromPtr(p capnp.Ptr) StateObject {
+	return StateObject(capnp.Struct{}.DecodeFromPtr(p))
+}
+
+func (s StateObject) ToPtr() capnp.Ptr {
+	return capnp.Struct(s).ToPtr()
+}
+func (s StateObject) IsValid() bool {
+	return capnp.Struct(s).IsValid()
+}
+
+func (s StateObject) Message() *capnp.Message {
+	return capnp.Struct(s).Message()
+}
+
+func (s StateObject) Segment() *capnp.Segment {
+	return capnp.Struct(s).Segment()
+}
+func (s StateObject) Version() uint32 {
+	return capnp.Struct(s).Uint32(0)
+}
+
+func (s StateObject) SetVersion(v uint32) {
+	capnp.Struct(s).SetUint32(0, v)
+}
+
+func (s StateObject) Id() (string, error) {
+	p, err := capnp.Struct(s).Ptr(0)
+	return p.Text(), err
+}
+
+func (s StateObject) HasId() bool {
+	return capnp.Struct(s).HasPtr(0)
+}
+
+func (s StateObject) IdBytes() ([]byte, error) {
+	p, err := capnp.Struct(s).Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s StateObject) SetId(v string) error {
+	return capnp.Struct(s).SetText(0, v)
+}
+
+func (s StateObject) State() (string, error) {
+	p, err := capnp.Struct(s).Ptr(1)
+	return p.Text(), err
+}
+
+func (s StateObject) HasState() bool {
+	return capnp.Struct(s).HasPtr(1)
+}
+
+func (s StateObject) StateBytes() ([]byte, error) {
+	p, err := capnp.Struct(s).Ptr(1)
+	return p.TextBytes(), err
+}
+
+func (s StateObject) SetState(v string) error {
+	return capnp.Struct(s).SetText(1, v)
+}
+
+func (s StateObject) EventId() (string, error) {
+	p, err := capnp.Struct(s).Ptr(2)
+	return p.Text(), err
+}
+
+func (s StateObject) HasEventId() bool {
+	return capnp.Struct(s).HasPtr(2)
+}
+
+func (s StateObject) EventIdBytes() ([]byte, error) {
+	p, err := capnp.Struct(s).Ptr(2)
+	return p.TextBytes(), err
+}
+
+func (s StateObject) SetEventId(v string) error {
+	return capnp.Struct(s).SetText(2, v)
+}
+
+func (s StateObject) WrittenAt() int64 {
+	return int64(capnp.Struct(s).Uint64(8))
+}
+
+func (s StateObject) SetWrittenAt(v int64) {
+	capnp.Struct(s).SetUint64(8, uint64(v))
+}
+
+func (s StateObject) Data() (Entry_List, error) {
+	p, err := capnp.Struct(s).Ptr(3)
+	return Entry_List(p.List()), err
+}
+
+func (s StateObject) HasData() bool {
+	return capnp.Struct(s).HasPtr(3)
+}
+
+func (s StateObject) SetData(v Entry_List) error {
+	return capnp.Struct(s).SetPtr(3, v.ToPtr())
+}
+
+// NewData sets the data field to a newly
+// allocated Entry_List, preferring placement in s's segment.
+func (s StateObject) NewData(n int32) (Entry_List, error) {
+	l, err := NewEntry_List(capnp.Struct(s).Segment(), n)
+	if err != nil {
+		return Entry_List{}, err
+	}
+	err = capnp.Struct(s).SetPtr(3, l.ToPtr())
+	return l, err
+}
+
+// StateObject_List is a list of StateObject.
+type StateObject_List = capnp.StructList[StateObject]
+
+// NewStateObject creates a new list of StateObject.
+func NewStateObject_List(s *capnp.Segment, sz int32) (StateObject_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 4}, sz)
+	return capnp.StructList[StateObject](l), err
+}
+
+// StateObject_Future is a wrapper for a StateObject promised by a client call.
+type StateObject_Future struct{ *capnp.Future }
+
+func (f StateObject_Future) Struct() (StateObject, error) {
+	p, err := f.Future.Ptr()
+	return StateObject(p.Struct()), err
+}
+
+type Entry capnp.Struct
+
+// Entry_TypeID is the unique identifier for the type Entry.
+const Entry_TypeID = 0xc1354485be5446d0
+
+func NewEntry(s *capnp.Segment) (Entry, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2})
+	return Entry(st), err
+}
+
+func NewRootEntry(s *capnp.Segment) (Entry, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2})
+	return Entry(st), err
+}
+
+func ReadRootEntry(msg *capnp.Message) (Entry, error) {
+	root, err := msg.Root()
+	return Entry(root.Struct()), err
+}
+
+func (s Entry) String() string {
+	str, _ := text.Marshal(0xc1354485be5446d0, capnp.Struct(s))
+	return str
+}
+
+func (s Entry) EncodeAsPtr(seg *capnp.Segment) capnp.Ptr {
+	return capnp.Struct(s).EncodeAsPtr(seg)
+}
+
+func (Entry) DecodeFromPtr(p capnp.Ptr) Entry {
+	return Entry(capnp.Struct{}.DecodeFromPtr(p))
+}
+
+func (s Entry) ToPtr() capnp.Ptr {
+	return capnp.Struct(s).ToPtr()
+}
+func (s Entry) IsValid() bool {
+	return capnp.Struct(s).IsValid()
+}
+
+func (s Entry) Message() *capnp.Message {
+	return capnp.Struct(s).Message()
+}
+
+func (s Entry) Segment() *capnp.Segment {
+	return capnp.Struct(s).Segment()
+}
+func (s Entry) Key() (string, error) {
+	p, err := capnp.Struct(s).Ptr(0)
+	return p.Text(), err
+}
+
+func (s Entry) HasKey() bool {
+	return capnp.Struct(s).HasPtr(0)
+}
+
+func (s Entry) KeyBytes() ([]byte, error) {
+	p, err := capnp.Struct(s).Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s Entry) SetKey(v string) error {
+	return capnp.Struct(s).SetText(0, v)
+}
+
+func (s Entry) Value() (Value, error) {
+	p, err := capnp.Struct(s).Ptr(1)
+	return Value(p.Struct()), err
+}
+
+func (s Entry) HasValue() bool {
+	return capnp.Struct(s).HasPtr(1)
+}
+
+func (s Entry) SetValue(v Value) error {
+	return capnp.Struct(s).SetPtr(1, capnp.Struct(v).ToPtr())
+}
+
+// NewValue sets the value field to a newly
+// allocated Value struct, preferring placement in s's segment.
+func (s Entry) NewValue() (Value, error) {
+	ss, err := NewValue(capnp.Struct(s).Segment())
+	if err != nil {
+		return Value{}, err
+	}
+	err = capnp.Struct(s).SetPtr(1, capnp.Struct(ss).ToPtr())
+	return ss, err
+}
+
+// Entry_List is a list of Entry.
+type Entry_List = capnp.StructList[Entry]
+
+// NewEntry creates a new list of Entry.
+func NewEntry_List(s *capnp.Segment, sz int32) (Entry_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2}, sz)
+	return capnp.StructList[Entry](l), err
+}
+
+// Entry_Future is a wrapper for a Entry promised by a client call.
+type Entry_Future struct{ *capnp.Future }
+
+func (f Entry_Future) Struct() (Entry, error) {
+	p, err := f.Future.Ptr()
+	return Entry(p.Struct()), err
+}
+func (p Entry_Future) Value() Value_Future {
+	return Value_Future{Future: p.Future.Field(1, nil)}
+}
+
+type Value capnp.Struct
+
+// Value_TypeID is the unique identifier for the type Value.
+const Value_TypeID = 0xb4713c608e85db62
+
+func NewValue(s *capnp.Segment) (Value, error) {
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 32, PointerCount: 4})
+	return Value(st), err
+}
+
+func NewRootValue(s *capnp.Segment) (Value, error) {
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 32, PointerCount: 4})
+	return Value(st), err
+}
+
+func ReadRootValue(msg *capnp.Message) (Value, error) {
+	root, err := msg.Root()
+	return Value(root.Struct()), err
+}
+
+func (s Value) String() string {
+	str, _ := text.Marshal(0xb4713c608e85db62, capnp.Struct(s))
+	return str
+}
+
+func (s Value) EncodeAsPtr(seg *capnp.Segment) capnp.Ptr {
+	return capnp.Struct(s).EncodeAsPtr(seg)
+}
+
+func (Value) DecodeFromPtr(p capnp.Ptr) Value {
+	return Value(capnp.Struct{}.DecodeFromPtr(p))
+}
+
+func (s Value) ToPtr() capnp.Ptr {
+	return capnp.Struct(s).ToPtr()
+}
+func (s Value) IsValid() bool {
+	return capnp.Struct(s).IsValid()
+}
+
+func (s Value) Message() *capnp.Message {
+	return capnp.Struct(s).Message()
+}
+
+func (s Value) Segment() *capnp.Segment {
+	return capnp.Struct(s).Segment()
+}
+func (s Value) Kind() Value_Kind {
+	return Value_Kind(capnp.Struct(s).Uint16(0))
+}
+
+func (s Value) SetKind(v Value_Kind) {
+	capnp.Struct(s).SetUint16(0, uint16(v))
+}
+
+func (s Value) BoolValue() bool {
+	return capnp.Struct(s).Bit(16)
+}
+
+func (s Value) SetBoolValue(v bool) {
+	capnp.Struct(s).SetBit(16, v)
+}
+
+func (s Value) IntValue() int64 {
+	return int64(capnp.Struct(s).Uint64(8))
+}
+
+func (s Value) SetIntValue(v int64) {
+	capnp.Struct(s).SetUint64(8, uint64(v))
+}
+
+func (s Value) UintValue() uint64 {
+	return capnp.Struct(s).Uint64(16)
+}
+
+func (s Value) SetUintValue(v uint64) {
+	capnp.Struct(s).SetUint64(16, v)
+}
+
+func (s Value) FloatValue() float64 {
+	return math.Float64frombits(capnp.Struct(s).Uint64(24))
+}
+
+func (s Value) SetFloatValue(v float64) {
+	capnp.Struct(s).SetUint64(24, math.Float64bits(v))
+}
+
+func (s Value) TextValue() (string, error) {
+	p, err := capnp.Struct(s).Ptr(0)
+	return p.Text(), err
+}
+
+func (s Value) HasTextValue() bool {
+	return capnp.Struct(s).HasPtr(0)
+}
+
+func (s Value) TextValueBytes() ([]byte, error) {
+	p, err := capnp.Struct(s).Ptr(0)
+	return p.TextBytes(), err
+}
+
+func (s Value) SetTextValue(v string) error {
+	return capnp.Struct(s).SetText(0, v)
+}
+
+func (s Value) BytesValue() ([]byte, error) {
+	p, err := capnp.Struct(s).Ptr(1)
+	return []byte(p.Data()), err
+}
+
+func (s Value) HasBytesValue() bool {
+	return capnp.Struct(s).HasPtr(1)
+}
+
+func (s Value) SetBytesValue(v []byte) error {
+	return capnp.Struct(s).SetData(1, v)
+}
+
+func (s Value) ListValue() (Value_List, error) {
+	p, err := capnp.Struct(s).Ptr(2)
+	return Value_List(p.List()), err
+}
+
+func (s Value) HasListValue() bool {
+	return capnp.Struct(s).HasPtr(2)
+}
+
+func (s Value) SetListValue(v Value_List) error {
+	return capnp.Struct(s).SetPtr(2, v.ToPtr())
+}
+
+// NewListValue sets the listValue field to a newly
+// allocated Value_List, preferring placement in s's segment.
+func (s Value) NewListValue(n int32) (Value_List, error) {
+	l, err := NewValue_List(capnp.Struct(s).Segment(), n)
+	if err != nil {
+		return Value_List{}, err
+	}
+	err = capnp.Struct(s).SetPtr(2, l.ToPtr())
+	return l, err
+}
+func (s Value) MapValue() (Entry_List, error) {
+	p, err := capnp.Struct(s).Ptr(3)
+	return Entry_List(p.List()), err
+}
+
+func (s Value) HasMapValue() bool {
+	return capnp.Struct(s).HasPtr(3)
+}
+
+func (s Value) SetMapValue(v Entry_List) error {
+	return capnp.Struct(s).SetPtr(3, v.ToPtr())
+}
+
+// NewMapValue sets the mapValue field to a newly
+// allocated Entry_List, preferring placement in s's segment.
+func (s Value) NewMapValue(n int32) (Entry_List, error) {
+	l, err := NewEntry_List(capnp.Struct(s).Segment(), n)
+	if err != nil {
+		return Entry_List{}, err
+	}
+	err = capnp.Struct(s).SetPtr(3, l.ToPtr())
+	return l, err
+}
+
+// Value_List is a list of Value.
+type Value_List = capnp.StructList[Value]
+
+// NewValue creates a new list of Value.
+func NewValue_List(s *capnp.Segment, sz int32) (Value_List, error) {
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 32, PointerCount: 4}, sz)
+	return capnp.StructList[Value](l), err
+}
+
+// Value_Future is a wrapper for a Value promised by a client call.
+type Value_Future struct{ *capnp.Future }
+
+func (f Value_Future) Struct() (Value, error) {
+	p, err := f.Future.Ptr()
+	return Value(p.Struct()), err
+}
+
+type Value_Kind uint16
+
+// Value_Kind_TypeID is the unique identifier for the type Value_Kind.
+const Value_Kind_TypeID = 0xd719e3964c891eb3
+
+// Values of Value_Kind.
+const (
+	Value_Kind_none  Value_Kind = 0
+	Value_Kind_bool  Value_Kind = 1
+	Value_Kind_int   Value_Kind = 2
+	Value_Kind_uint  Value_Kind = 3
+	Value_Kind_float Value_Kind = 4
+	Value_Kind_text  Value_Kind = 5
+	Value_Kind_bytes Value_Kind = 6
+	Value_Kind_time  Value_Kind = 7
+	Value_Kind_list  Value_Kind = 8
+	Value_Kind_map   Value_Kind = 9
+)
+
+// String returns the enum's constant name.
+func (c Value_Kind) String() string {
+	switch c {
+	case Value_Kind_none:
+		return "none"
+	case Value_Kind_bool:
+		return "bool"
+	case Value_Kind_int:
+		return "int"
+	case Value_Kind_uint:
+		return "uint"
+	case Value_Kind_float:
+		return "float"
+	case Value_Kind_text:
+		return "text"
+	case Value_Kind_bytes:
+		return "bytes"
+	case Value_Kind_time:
+		return "time"
+	case Value_Kind_list:
+		return "list"
+	case Value_Kind_map:
+		return "map"
+
+	default:
+		return ""
+	}
+}
+
+// Value_KindFromString returns the enum value with a name,
+// or the zero value if there's no such value.
+func Value_KindFromString(c string) Value_Kind {
+	switch c {
+	case "none":
+		return Value_Kind_none
+	case "bool":
+		return Value_Kind_bool
+	case "int":
+		return Value_Kind_int
+	case "uint":
+		return Value_Kind_uint
+	case "float":
+		return Value_Kind_float
+	case "text":
+		return Value_Kind_text
+	case "bytes":
+		return Value_Kind_bytes
+	case "time":
+		return Value_Kind_time
+	case "list":
+		return Value_Kind_list
+	case "map":
+		return Value_Kind_map
+
+	default:
+		return 0
+	}
+}
+
+type Value_Kind_List = capnp.EnumList[Value_Kind]
+
+func NewValue_Kind_List(s *capnp.Segment, sz int32) (Value_Kind_List, error) {
+	return capnp.NewEnumList[Value_Kind](s, sz)
+}
+
+const schema_f90dd8b0ff674d80 = "x\xda|SOh\x1ce\x1c}\xef\xfbfv6t" +
+	"\xb7\xb3\x1f3\xc5V\xd4\x95\x12!\x0dX\x9a\x8a\xa0\"" +
+	"\x98H\x15Z\x15\xfd\xb2\xa2\xe8\xc5\xcevG\x19\xbb\x99" +
+	"\xddf'\xa99\xd5K\xc1\x1e\xf4$EA\xa1B\x84" +
+	"\x08\x11\x14sV<x\x09\x1e\xf4 *z\xd3\x83\x17" +
+	"/A\xf1\x0f\xca'\xbfIv\xb2H\xf4\xf4\xf1{\xf3" +
+	"f~o\xde{\xdf\xa9\xeb\x9c\xf7\xe6\x9a\x9f\x10\xca\xc6" +
+	"~\xcdu\xbf\xbb\xfa\xda\xf9\xfb/m\xc1F\xf4\xdc\xcb" +
+	"\x8f\xbd\xe0>\xf8\xa6\xf9\x07|/\x00\xccg\xdb\xe6\xcb" +
+	"\x9b\x80\xb9o\x1dA\xf7\xc5\xc3O~|\xf5\xcc\xdd\x9f" +
+	"\xc2D\x9c`\xaa\x00\x88\xee\xd0\xdb`tB_\x06\xdd" +
+	"G\xb7]{\xf4\xfa\x0f\xc7\xbe\x869\xa6\xf6\xbf\x0fF" +
+	"\xd7\xf4\x0e\x18\xbd\xaa\x7f\x03\xdd\x8f\xc1W\xeb\xaf\xbc\xbf" +
+	"\xf3\xbd\xecU\xff\xda\x1b\x19\xefO\xf0\xae#\xde\xd3D" +
+	"\xc3\x8d\x8a\xa4H\x9f\x1bt\xd5\x8b\xe9\x85\xe2\xe4\x85d" +
+	"\x98\x0f\xef{*\xe9\xaf\xa4\x80\xf58\xb9\x8e\xb3\xe1#" +
+	"Y\xde\xb3\xd3\xda\x03<\x02\xe6\xe7Y\xc0\xfe\xa4i\x7f" +
+	"W4l\xc5\x14\xf0\xd7E\xc0\xfe\xa2\xb9HE\xa3\x18" +
+	"S\x01\xe6\xefs\x80\xfdK\xb3S\x17T\xab\x98\x1a\x88" +
+	"|.\x02\x1d\x8f\x9a\x9d\x96\xe0\x9e\x8e\xe9\x01Q\x93\xcf" +
+	"\x02\x9d\x86\xe0G\xa9H?\xa6\x0fDGJz,\xf0" +
+	"\xedB\xaf1f\x0d\x88n-\xe9\xb7\x08>#x\xa0" +
+	"b\x96\xae\x95\xfci\xc1\xcf\x08^\xd71\xeb@\xb4\xc0" +
+	"s@g^\xf0\xf3T\x0c/fy\x8f\xe1\xfe\x9f\x02" +
+	"\xf3\x04\x18\x82\xae;\x18\xf4\xc5\x0d0%\xa1H\xd0e" +
+	"y\xb1g\x10\xe8C\xd1\x07\xdd\xca\x1e(\xbc)(N" +
+	"\x81\xee\xf9\xfe \x11\x10z%\xe5!(\x1e\x02]\x91" +
+	"\xbeT\x11\x1bPl\xc8\x92\xb5\"\x1d\x8d\x89M(6" +
+	"A\xd7\xcfF\x15\xf10\xf8\x84&[\x13q\xefJ<" +
+	"\x0c\xba\xa5dX\xc9\xa9\x88U\x99&\x88\x07%\xfdP" +
+	"^,\xaf\x01\xb6^ez\xe28`\xa75\xed)\xc9" +
+	"\x94\xbb\x99\xdey\x1a\xb03\x9a\xf6\x1e\xc5\xe0b\xba6" +
+	"\x96\xde^\x95\xcd\x07\x08k\xfd\xc7\xbeRi\xfbd\xd9" +
+	"\xa3\x99\xb2\x19\x97f\x01\xd2dr(\x93\x1c\x07\xa8\xcd" +
+	"32y\xc6\x9e\x16\x8b\xcdY\x99jfA\xa6\xc0\xdc" +
+	"+S\xdd\xcc\xc91U\xca\x0d\xf3A\x9e\x86\x12U\x90" +
+	"\xe5E(Y\xb4K\xf3Cq\xbb]\xda\x1b\x16\xd9R" +
+	"\x1a\x8a\xa9\xc1R2<P[G\xb0\x07\x1e\xef\x0ad" +
+	"\x8fV\x86\xbc\xf9 `_\xd7\xb47\x14\xc7~\xbc}" +
+	"3`\xdf\xd0\xb4\xeb\x13\x1d\x7fGLzK\xd3n\xec" +
+	"W\xdc\xbc+o\xdf\xd0\xb4\x9b\xd2o\x96\xfd6\xef\xc9" +
+	"\x15\xd9\xd0\xb4[\x8a\xc6\xd7e\xbb\xcd\x87r\x9965" +
+	"\xed\xe7\x8aWV\xd3\xe5Q6\xc8Y\x87b\x1d\xd4Y" +
+	"\xaf\xb2\xbc\x94>\x9e\xae\xa4\xabi^\x9c\xad\x9e\xba\xcb" +
+	"\xcbYQ\xa4\xf9\x02X\x8c\xcb\x19\xf6\x92\"\xf9\xdfj" +
+	"\xfc3\x00_\x09\x0b\x15"
+
+func init() {
+	schemas.Register(schema_f90dd8b0ff674d80,
+		0xb4713c608e85db62,
+		0xc1354485be5446d0,
+		0xd719e3964c891eb3,
+		0xdcf2ad88a4d507e4)
+}
@@ -0,0 +1,269 @@
+// Envelope of a StateObject serialized by ProtobufSerialization. Objects
+// stored by the state machine and the events it emits use this message, so
+// consumers in other languages can read them.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.1
+// 	protoc        (unknown)
+// source: state_object.proto
+
+package protomodels
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StateObject struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Version of the envelope. Readers reject versions they don't know.
+	Version uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	// ID identifies the object in the store.
+	Id string `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	// State is the current state of the object.
+	State string `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	// EventID identifies the event of the last transition.
+	EventId string `protobuf:"bytes,4,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// WrittenAt is the time the object was serialized.
+	WrittenAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=written_at,json=writtenAt,proto3" json:"written_at,omitempty"`
+	// Data holds the data of the object, as a struct for map data or as a
+	// message for typed objects whose data is a protobuf message.
+	//
+	// Types that are assignable to Data:
+	//	*StateObject_StructData
+	//	*StateObject_MessageData
+	Data isStateObject_Data `protobuf_oneof:"data"`
+}
+
+func (x *StateObject) Reset() {
+	*x = StateObject{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_state_object_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StateObject) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StateObject) ProtoMessage() {}
+
+func (x *StateObject) ProtoReflect() protoreflect.Message {
+	mi := &file_state_object_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StateObject.ProtoReflect.Descriptor instead.
+func (*StateObject) Descriptor() ([]byte, []int) {
+	return file_state_object_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StateObject) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *StateObject) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *StateObject) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *StateObject) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *StateObject) GetWrittenAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WrittenAt
+	}
+	return nil
+}
+
+func (m *StateObject) GetData() isStateObject_Data {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (x *StateObject) GetStructData() *structpb.Struct {
+	if x, ok := x.GetData().(*StateObject_StructData); ok {
+		return x.StructData
+	}
+	return nil
+}
+
+func (x *StateObject) GetMessageData() *anypb.Any {
+	if x, ok := x.GetData().(*StateObject_MessageData); ok {
+		return x.MessageData
+	}
+	return nil
+}
+
+type isStateObject_Data interface {
+	isStateObject_Data()
+}
+
+type StateObject_StructData struct {
+	StructData *structpb.Struct `protobuf:"bytes,6,opt,name=struct_data,json=structData,proto3,oneof"`
+}
+
+type StateObject_MessageData struct {
+	MessageData *anypb.Any `protobuf:"bytes,7,opt,name=message_data,json=messageData,proto3,oneof"`
+}
+
+func (*StateObject_StructData) isStateObject_Data() {}
+
+func (*StateObject_MessageData) isStateObject_Data() {}
+
+var File_state_object_proto protoreflect.FileDescriptor
+
+var file_state_object_proto_rawDesc = []byte{
+	0x0a, 0x12, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x16, 0x68, 0x69, 0x62, 0x72, 0x69, 0x64, 0x2e, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x2e, 0x76, 0x31, 0x1a, 0x19, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x61, 0x6e,
+	0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1c, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xa2, 0x02, 0x0a, 0x0b, 0x53, 0x74, 0x61, 0x74, 0x65,
+	0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64,
+	0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x5f,
+	0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x49,
+	0x64, 0x12, 0x39, 0x0a, 0x0a, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x5f, 0x61, 0x74, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x52, 0x09, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x41, 0x74, 0x12, 0x3a, 0x0a, 0x0b,
+	0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x17, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x48, 0x00, 0x52, 0x0a, 0x73, 0x74,
+	0x72, 0x75, 0x63, 0x74, 0x44, 0x61, 0x74, 0x61, 0x12, 0x39, 0x0a, 0x0c, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x41, 0x6e, 0x79, 0x48, 0x00, 0x52, 0x0b, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x44,
+	0x61, 0x74, 0x61, 0x42, 0x06, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x42, 0x71, 0x0a, 0x21, 0x63,
+	0x6f, 0x6d, 0x2e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x68, 0x69, 0x62, 0x72, 0x69, 0x64,
+	0x2e, 0x73, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x2e, 0x76, 0x31,
+	0x42, 0x10, 0x53, 0x74, 0x61, 0x74, 0x65, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x50, 0x72, 0x6f,
+	0x74, 0x6f, 0x50, 0x01, 0x5a, 0x38, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f, 0x68, 0x69, 0x62, 0x72, 0x69, 0x64, 0x2f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x61, 0x63,
+	0x68, 0x69, 0x6e, 0x65, 0x2f, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x69, 0x7a, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x73, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_state_object_proto_rawDescOnce sync.Once
+	file_state_object_proto_rawDescData = file_state_object_proto_rawDesc
+)
+
+func file_state_object_proto_rawDescGZIP() []byte {
+	file_state_object_proto_rawDescOnce.Do(func() {
+		file_state_object_proto_rawDescData = protoimpl.X.CompressGZIP(file_state_object_proto_rawDescData)
+	})
+	return file_state_object_proto_rawDescData
+}
+
+var file_state_object_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_state_object_proto_goTypes = []interface{}{
+	(*StateObject)(nil),           // 0: hibrid.statemachine.v1.StateObject
+	(*timestamppb.Timestamp)(nil), // 1: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),       // 2: google.protobuf.Struct
+	(*anypb.Any)(nil),             // 3: google.protobuf.Any
+}
+var file_state_object_proto_depIdxs = []int32{
+	1, // 0: hibrid.statemachine.v1.StateObject.written_at:type_name -> google.protobuf.Timestamp
+	2, // 1: hibrid.statemachine.v1.StateObject.struct_data:type_name -> google.protobuf.Struct
+	3, // 2: hibrid.statemachine.v1.StateObject.message_data:type_name -> google.protobuf.Any
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_state_object_proto_init() }
+func file_state_object_proto_init() {
+	if File_state_object_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_state_object_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StateObject); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_state_object_proto_msgTypes[0].OneofWrappers = []interface{}{
+		(*StateObject_StructData)(nil),
+		(*StateObject_MessageData)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_state_object_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_state_object_proto_goTypes,
+		DependencyIndexes: file_state_object_proto_depIdxs,
+		MessageInfos:      file_state_object_proto_msgTypes,
+	}.Build()
+	File_state_object_proto = out.File
+	file_state_object_proto_rawDesc = nil
+	file_state_object_proto_goTypes = nil
+	file_state_object_proto_depIdxs = nil
+}
@@ -0,0 +1,171 @@
+package statemachine
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// prefixedSerialization writes JSON behind a prefix, so reading it as plain
+// JSON fails.
+type prefixedSerialization struct{}
+
+func (prefixedSerialization) ContentType() string {
+	return "application/x-prefixed-json"
+}
+
+func (prefixedSerialization) Serialize(so *StateObject) ([]byte, error) {
+	data, err := JSONSerialization{}.Serialize(so)
+	return append([]byte("prefixed:"), data...), err
+}
+
+func (prefixedSerialization) Deserialize(data []byte) (*StateObject, error) {
+	return JSONSerialization{}.Deserialize(bytes.TrimPrefix(data, []byte("prefixed:")))
+}
+
+func TestSerializeUsesConfiguredSerializer(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{Serializer: prefixedSerialization{}})
+
+	so := NewStateObject(map[string]interface{}{"iccid": "8901"}, sm, nil)
+	data, err := so.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("prefixed:")) {
+		t.Errorf("Expected the configured serializer to be used but got %s", data)
+	}
+}
+
+func TestSetSerializerKeepsHandlerConfig(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetSerializer(prefixedSerialization{})
+
+	if _, ok := sm.serializer().(prefixedSerialization); !ok {
+		t.Errorf("Expected the serializer to be set but got %T", sm.serializer())
+	}
+	config := defaultConfig
+	config.Serializer = prefixedSerialization{}
+	if sm.config != config {
+		t.Errorf("Expected the default handlers to stay on but got %+v", sm.config)
+	}
+}
+
+func TestDeserializeAfterSwitchingSerializer(t *testing.T) {
+	sm := newTestStateMachine()
+	so := NewStateObject(map[string]interface{}{"iccid": "8901"}, sm, nil)
+	so.State = SIMActivated
+
+	sm.SetHandlerConfig(HandlerConfig{Serializer: JSONSerialization{}})
+	asJSON, err := sm.encode(so)
+	if err != nil {
+		t.Fatalf("Encoding as JSON failed: %v", err)
+	}
+	sm.SetHandlerConfig(HandlerConfig{Serializer: prefixedSerialization{}})
+	asPrefixed, err := sm.encode(so)
+	if err != nil {
+		t.Fatalf("Encoding with the new serializer failed: %v", err)
+	}
+
+	for name, data := range map[string][]byte{"json": asJSON, "prefixed": asPrefixed} {
+		decoded, err := Deserialize(data)
+		if err != nil {
+			t.Fatalf("Deserialize of %s failed: %v", name, err)
+		}
+		if decoded.State != SIMActivated || decoded.Data["iccid"] != "8901" {
+			t.Errorf("Expected the %s object to be read back but got %+v", name, decoded)
+		}
+	}
+}
+
+func TestDeserializeUnknownContentType(t *testing.T) {
+	data := append([]byte{blobMarker}, "application/x-unknown\n{}"...)
+	if _, err := Deserialize(data); !errors.Is(err, ErrUnknownContentType) {
+		t.Errorf("Expected ErrUnknownContentType but got %v", err)
+	}
+}
+
+type recordingPublisher struct {
+	contentType string
+	payload     []byte
+}
+
+func (p *recordingPublisher) Publish(contentType string, payload []byte) error {
+	p.contentType, p.payload = contentType, payload
+	return nil
+}
+
+func TestEmitEventUsesConfiguredSerializer(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{Serializer: prefixedSerialization{}})
+	publisher := &recordingPublisher{}
+	sm.SetConfig(Config{KafkaConn: publisher})
+
+	sm.EmitEvent(NewStateObject(map[string]interface{}{}, sm, nil))
+	if publisher.contentType != "application/x-prefixed-json" || !bytes.HasPrefix(publisher.payload, []byte("prefixed:")) {
+		t.Errorf("Expected the event to be encoded with the configured serializer but got %s %s", publisher.contentType, publisher.payload)
+	}
+}
+
+// testPreservesTypes checks that the integers, byte slices and times of
+// Data, and typed data, are read back with their Go types, and that lists,
+// maps and nil values survive.
+func testPreservesTypes(t *testing.T, serializer Serialization) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{Serializer: serializer})
+
+	activated := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	so := NewStateObject(map[string]interface{}{
+		"imsi":      int64(310150123456789),
+		"ki":        []byte{0x01, 0x02},
+		"activated": activated,
+		"carriers":  []string{"TelecomProvider"},
+		"limits":    map[string]interface{}{"data": 1.5, "roaming": true, "sims": 5},
+		"note":      nil,
+	}, sm, nil)
+	so.ID, so.EventID, so.State = "sim-8901", "evt-1", SIMActivated
+	stored, err := sm.encode(so)
+	if err != nil {
+		t.Fatalf("Encoding failed: %v", err)
+	}
+
+	decoded, err := Deserialize(stored)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if decoded.ID != so.ID || decoded.EventID != so.EventID || decoded.State != so.State {
+		t.Errorf("Expected %+v but got %+v", so, decoded)
+	}
+	if decoded.Data["imsi"] != int64(310150123456789) {
+		t.Errorf("Expected the IMSI to stay an int64 but got %T %v", decoded.Data["imsi"], decoded.Data["imsi"])
+	}
+	if ki, ok := decoded.Data["ki"].([]byte); !ok || !bytes.Equal(ki, []byte{0x01, 0x02}) {
+		t.Errorf("Expected the key as bytes but got %T %v", decoded.Data["ki"], decoded.Data["ki"])
+	}
+	if at, ok := decoded.Data["activated"].(time.Time); !ok || !at.Equal(activated) {
+		t.Errorf("Expected the activation time but got %T %v", decoded.Data["activated"], decoded.Data["activated"])
+	}
+	if limits, ok := decoded.Data["limits"].(map[string]interface{}); !ok || limits["data"] != 1.5 || limits["roaming"] != true || limits["sims"] != int64(5) {
+		t.Errorf("Expected the limits but got %T %v", decoded.Data["limits"], decoded.Data["limits"])
+	}
+	if carriers, ok := decoded.Data["carriers"].([]interface{}); !ok || len(carriers) != 1 || carriers[0] != "TelecomProvider" {
+		t.Errorf("Expected the carriers but got %v", decoded.Data["carriers"])
+	}
+	if note, ok := decoded.Data["note"]; !ok || note != nil {
+		t.Errorf("Expected a nil note but got %v", note)
+	}
+
+	sim := NewTypedStateObject(simCard{ICCID: "8901", IMSI: 310150123456789, Carriers: []string{"TelecomProvider"}}, sm, nil)
+	stored, err = sm.encode(sim.StateObject)
+	if err != nil {
+		t.Fatalf("Encoding failed: %v", err)
+	}
+	typed, err := DeserializeTyped[simCard](sm, stored)
+	if err != nil {
+		t.Fatalf("DeserializeTyped failed: %v", err)
+	}
+	if typed.Data.ICCID != "8901" || typed.Data.IMSI != 310150123456789 || len(typed.Data.Carriers) != 1 {
+		t.Errorf("Expected %+v but got %+v", sim.Data, typed.Data)
+	}
+}
@@ -0,0 +1,109 @@
+package statemachine
+
+import "reflect"
+
+// WithoutDataSnapshot stops this transition from copying Data before its
+// handlers run. Use it for very large objects where the copy is too costly;
+// handlers are then responsible for undoing their changes to Data in
+// Rollback.
+func (t *StateTransition) WithoutDataSnapshot() *StateTransition {
+	t.SkipDataSnapshot = true
+	return t
+}
+
+// copyData returns a deep copy of the Data of a StateObject. Maps, slices
+// and the exported fields of structs are copied all the way down; values
+// behind pointers are shared.
+func copyData(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		copied[key] = copyValue(value)
+	}
+	return copied
+}
+
+// snapshotData takes a deep copy of Data, and of the typed data of a
+// TypedStateObject, and returns a function restoring the copy.
+func (so *StateObject) snapshotData() func() {
+	data := copyData(so.Data)
+	if so.typed == nil {
+		return func() {
+			so.Data = data
+		}
+	}
+	typed := reflect.ValueOf(so.typed).Elem()
+	copied := copyReflectValue(typed)
+	return func() {
+		so.Data = data
+		typed.Set(copied)
+	}
+}
+
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		return copyData(v)
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	case []byte:
+		return append([]byte(nil), v...)
+	}
+	return copyReflectValue(reflect.ValueOf(value)).Interface()
+}
+
+func copyReflectValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), copyReflectValue(iter.Value()))
+		}
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(copyReflectValue(v.Index(i)))
+		}
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if copied.Field(i).CanSet() {
+				copied.Field(i).Set(copyReflectValue(v.Field(i)))
+			}
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(copyReflectValue(v.Index(i)))
+		}
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(copyReflectValue(v.Elem()))
+		return copied
+	default:
+		return v
+	}
+}
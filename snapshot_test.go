@@ -0,0 +1,106 @@
+package statemachine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCopyData(t *testing.T) {
+	data := map[string]interface{}{
+		"carrier":  "TelecomProvider",
+		"imsi":     310150123456789,
+		"numbers":  []interface{}{"1234567890"},
+		"billing":  map[string]interface{}{"plan": "basic"},
+		"tags":     []string{"prepaid"},
+		"limits":   map[string]int{"sms": 100},
+		"raw":      []byte{1, 2, 3},
+		"optional": nil,
+	}
+	copied := copyData(data)
+	if !reflect.DeepEqual(data, copied) {
+		t.Fatalf("Expected copy to equal the original but got %v", copied)
+	}
+
+	copied["numbers"].([]interface{})[0] = "0987654321"
+	copied["billing"].(map[string]interface{})["plan"] = "premium"
+	copied["tags"].([]string)[0] = "postpaid"
+	copied["limits"].(map[string]int)["sms"] = 0
+	copied["raw"].([]byte)[0] = 9
+
+	if data["numbers"].([]interface{})[0] != "1234567890" ||
+		data["billing"].(map[string]interface{})["plan"] != "basic" ||
+		data["tags"].([]string)[0] != "prepaid" ||
+		data["limits"].(map[string]int)["sms"] != 100 ||
+		data["raw"].([]byte)[0] != 1 {
+		t.Errorf("Expected changes to the copy to leave the original untouched but got %v", data)
+	}
+}
+
+func mutateBillingHandler() Handler {
+	return NewHandler("", func(ctx context.Context, tc *TransitionContext) error {
+		tc.Object.Data["billing"].(map[string]interface{})["plan"] = "premium"
+		tc.Object.Data["activated"] = true
+		return nil
+	}, nil)
+}
+
+func TestTransitionRestoresDataOnFailure(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		mutateBillingHandler(),
+		FromContextHandler(&mockContextHandler{err: errCarrierUnavailable}),
+	)
+
+	so := NewStateObject(map[string]interface{}{
+		"billing": map[string]interface{}{"plan": "basic"},
+	}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err == nil {
+		t.Fatalf("Expected transition to fail")
+	}
+
+	expected := map[string]interface{}{
+		"billing": map[string]interface{}{"plan": "basic"},
+	}
+	if !reflect.DeepEqual(so.Data, expected) {
+		t.Errorf("Expected Data to be restored to %v but got %v", expected, so.Data)
+	}
+}
+
+func TestTransitionWithoutDataSnapshot(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.RegisterTransition(SIMNotActivated, SIMActivated,
+		mutateBillingHandler(),
+		FromContextHandler(&mockContextHandler{err: errCarrierUnavailable}),
+	).WithoutDataSnapshot()
+
+	so := NewStateObject(map[string]interface{}{
+		"billing": map[string]interface{}{"plan": "basic"},
+	}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err == nil {
+		t.Fatalf("Expected transition to fail")
+	}
+	if so.Data["activated"] != true {
+		t.Errorf("Expected Data to keep the changes without a snapshot")
+	}
+}
+
+func TestTransitionKeepsDataOnSuccess(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, mutateBillingHandler())
+
+	so := NewStateObject(map[string]interface{}{
+		"billing": map[string]interface{}{"plan": "basic"},
+	}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+	if so.Data["billing"].(map[string]interface{})["plan"] != "premium" {
+		t.Errorf("Expected Data to keep the changes of a successful transition")
+	}
+}
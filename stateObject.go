@@ -13,33 +13,73 @@ import (
 )
 
 type StateObject struct {
+	// ID identifies the object in the store. Objects without an ID are
+	// stored under their EventID.
+	ID         string                 `json:"id,omitempty"`
 	Data       map[string]interface{} `json:"data"`
 	State      string                 `json:"state"`
 	EventID    string                 `json:"eventID"`
-	Logger     *zap.Logger
-	CommitFunc func() error `json:"-"`
+	Logger     *zap.Logger            `json:"-"`
+	CommitFunc func() error           `json:"-"`
+
+	// machine is the StateMachine whose serializer writes the object.
+	machine *StateMachine
+
+	// typed points to the data of a TypedStateObject, which is stored in
+	// place of Data.
+	typed interface{}
+}
+
+// MarshalJSON writes the typed data of a TypedStateObject as the data of
+// the object.
+func (so *StateObject) MarshalJSON() ([]byte, error) {
+	type plain StateObject
+	if so.typed == nil {
+		return json.Marshal((*plain)(so))
+	}
+	return json.Marshal(struct {
+		*plain
+		Data interface{} `json:"data"`
+	}{(*plain)(so), so.typed})
 }
 
-func NewStateObjectFromStruct(data interface{}, sm *StateMachine, logger *zap.Logger) *StateObject {
+// UnmarshalJSON reads the data of the object into the typed data of a
+// TypedStateObject.
+func (so *StateObject) UnmarshalJSON(data []byte) error {
+	type plain StateObject
+	if so.typed == nil {
+		return json.Unmarshal(data, (*plain)(so))
+	}
+	return json.Unmarshal(data, &struct {
+		*plain
+		Data interface{} `json:"data"`
+	}{(*plain)(so), so.typed})
+}
+
+// NewStateObjectFromStruct creates a StateObject whose Data is encoded from
+// the given struct. It returns an error if the struct can't be encoded.
+func NewStateObjectFromStruct(data interface{}, sm *StateMachine, logger *zap.Logger) (*StateObject, error) {
 	var state = &StateObject{
-		State:  SIMNotActivated, // or some other default state
-		Logger: logger,
+		State:   sm.initialState(),
+		Logger:  logger,
+		machine: sm,
 	}
 	err := state.EncodeObjectToData(data)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	state.CommitFunc = func() error {
 		return state.actualCommitToDisk(sm)
 	}
-	return state
+	return state, nil
 }
 
 func NewStateObject(data map[string]interface{}, sm *StateMachine, logger *zap.Logger) *StateObject {
 	var state = &StateObject{
-		Data:   data,
-		State:  SIMNotActivated, // or some other default state
-		Logger: logger,
+		Data:    data,
+		State:   sm.initialState(),
+		Logger:  logger,
+		machine: sm,
 	}
 	state.CommitFunc = func() error {
 		return state.actualCommitToDisk(sm)
@@ -51,45 +91,72 @@ func (so *StateObject) CommitToDisk() error {
 	return so.CommitFunc()
 }
 
+// Serialize encodes the object with the serializer of its StateMachine.
 func (so *StateObject) Serialize() ([]byte, error) {
-	return json.Marshal(so)
+	return so.machine.serializer().Serialize(so)
 }
 
 var writeFileFunc = os.WriteFile
 
 func (so *StateObject) actualCommitToDisk(sm *StateMachine) error {
-	serializedData, err := so.Serialize()
+	serializedData, err := sm.encode(so)
 	if err != nil {
 		return err
 	}
-	err = sm.redisClient.Set(context.Background(), so.EventID, serializedData, 0).Err()
+	err = sm.redisClient.Set(context.Background(), so.storageKey(), serializedData, 0).Err()
 	return err
 }
 
+// storageKey returns the key the object is stored under.
+func (so *StateObject) storageKey() string {
+	if so.ID != "" {
+		return "state:" + so.ID
+	}
+	return so.EventID
+}
+
 var nowFunc = time.Now
 
 func (so *StateObject) LogTransition(from, to string, sm *StateMachine) {
-	log := StateTransitionLog{
+	so.logTransition(StateTransitionLog{
 		EventID:   so.EventID,
 		FromState: from,
 		ToState:   to,
 		Timestamp: nowFunc(),
+	}, sm)
+}
+
+// logTransition writes the transition log, including the attempts of
+// retried handlers and the error of a failed chain, to the logger of the
+// object.
+func (so *StateObject) logTransition(log StateTransitionLog, sm *StateMachine) {
+	if so.Logger == nil {
+		return
+	}
+
+	var attempts string
+	for _, attempt := range log.Attempts {
+		attempts += fmt.Sprintf("{handler: %s, attempt: %d, error: %v}", attempt.Handler, attempt.Attempt, attempt.Err)
+	}
+	var failure string
+	if log.Err != nil {
+		failure = fmt.Sprintf(", error: %v", log.Err)
 	}
 
 	if sm.DebugLogging {
 		// Get caller info only if DebugLogging is enabled
-		pc, file, line, _ := runtime.Caller(1)
+		pc, file, line, _ := runtime.Caller(2)
 		funcName := runtime.FuncForPC(pc).Name()
 
 		file = filepath.Base(file)
-		logStr := fmt.Sprintf("{timestamp: %s, event_id: %s, from_state: %s, to_state: %s, func: %s, file: %s, line: %d}\n",
-			log.Timestamp, log.EventID, log.FromState, log.ToState, funcName, file, line)
+		logStr := fmt.Sprintf("{timestamp: %s, event_id: %s, from_state: %s, to_state: %s, attempts: [%s]%s, func: %s, file: %s, line: %d}\n",
+			log.Timestamp, log.EventID, log.FromState, log.ToState, attempts, failure, funcName, file, line)
 		// Structured logging with debug info to stdout
 		so.Logger.Debug("log_transition", zap.String("transition", logStr))
 	} else {
 		// Structured logging without debug info
-		logStr := fmt.Sprintf("{timestamp: %s, event_id: %s, from_state: %s, to_state: %s}\n",
-			log.Timestamp, log.EventID, log.FromState, log.ToState)
+		logStr := fmt.Sprintf("{timestamp: %s, event_id: %s, from_state: %s, to_state: %s, attempts: [%s]%s}\n",
+			log.Timestamp, log.EventID, log.FromState, log.ToState, attempts, failure)
 		so.Logger.Debug("log_transition", zap.String("transition", logStr))
 
 	}
@@ -91,3 +91,25 @@ func TestMarkProcessed(t *testing.T) {
 		t.Errorf("Expected state to be Processed but got %s", so.State)
 	}
 }
+
+func TestNewStateObjectFromStruct(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	sm := NewStateMachine("localhost:6379")
+
+	so, err := NewStateObjectFromStruct(struct {
+		PhoneNumber string
+	}{PhoneNumber: "1234567890"}, sm, logger)
+	if err != nil {
+		t.Fatalf("NewStateObjectFromStruct failed: %v", err)
+	}
+	if so.Data["PhoneNumber"] != "1234567890" {
+		t.Errorf("Data not set correctly in StateObject")
+	}
+
+	_, err = NewStateObjectFromStruct(struct {
+		Updates chan int
+	}{Updates: make(chan int)}, sm, logger)
+	if err == nil {
+		t.Fatalf("Expected an error for data that can't be encoded")
+	}
+}
@@ -0,0 +1,275 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Step transitions one StateObject as part of TransitionAll.
+type Step struct {
+	Object *StateObject
+	To     string
+}
+
+// AtomicCommit holds the results of the transitions of TransitionAll, which
+// are written all together or not at all.
+type AtomicCommit struct {
+	// Objects are the serialized objects in their new state, by storage key.
+	Objects map[string][]byte
+	// Processed are the event IDs to mark as processed.
+	Processed []string
+}
+
+// AtomicStore writes an AtomicCommit in a single transaction, such as a
+// Redis script or an SQL transaction. When one of the event IDs is already
+// marked processed, Commit writes nothing and returns ErrAlreadyProcessed.
+type AtomicStore interface {
+	Commit(ctx context.Context, commit AtomicCommit) error
+}
+
+// ErrMissingObjectID is returned by TransitionAll for an object without an
+// ID, since the object could not be told apart from the others in the store.
+var ErrMissingObjectID = errors.New("state object has no ID")
+
+// TransitionAllError is returned by TransitionAll when the handler chain of
+// a step or the commit fails. Nothing is committed in either case.
+type TransitionAllError struct {
+	// Step is the index of the step that failed, or -1 when the commit
+	// failed.
+	Step int
+	Err  error
+	// Rollback holds the failures to roll back the other steps.
+	Rollback error
+}
+
+func (e *TransitionAllError) Error() string {
+	msg := fmt.Sprintf("step %d failed: %v", e.Step, e.Err)
+	if e.Step < 0 {
+		msg = fmt.Sprintf("commit failed: %v", e.Err)
+	}
+	if e.Rollback != nil {
+		msg += fmt.Sprintf("; rollback failed: %v", e.Rollback)
+	}
+	return msg
+}
+
+func (e *TransitionAllError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports a TransitionAllError whose rollback failed as ErrRollbackFailed.
+func (e *TransitionAllError) Is(target error) bool {
+	return target == ErrRollbackFailed && e.Rollback != nil
+}
+
+// SetAtomicStore sets the store TransitionAll commits to. By default it
+// commits to the Redis instance of the StateMachine.
+func (sm *StateMachine) SetAtomicStore(store AtomicStore) {
+	sm.atomicStore = store
+}
+
+// pendingStep is a step of TransitionAll whose handlers ran but whose result
+// is not committed yet.
+type pendingStep struct {
+	Step
+	transition *StateTransition
+	tc         *TransitionContext
+	executed   []executedHandler
+	restore    func()
+}
+
+// TransitionAll transitions several objects that live in the same store
+// together. The handler chain of every step runs first; the resulting states
+// and processed markers are then committed in a single transaction, so
+// either all objects move or none do. The markprocessed handler doesn't run
+// during the chains since its marker is part of the commit. When a chain or
+// the commit fails, the handlers of the other steps are rolled back and the
+// objects keep their states.
+func (sm *StateMachine) TransitionAll(ctx context.Context, steps []Step) error {
+	pending := make([]*pendingStep, 0, len(steps))
+	ids := make(map[string]bool, len(steps))
+	for i, step := range steps {
+		if step.Object.ID == "" {
+			return fmt.Errorf("step %d: %w", i, ErrMissingObjectID)
+		}
+		if ids[step.Object.ID] {
+			return fmt.Errorf("step %d: object %s is transitioned more than once", i, step.Object.ID)
+		}
+		ids[step.Object.ID] = true
+
+		transition, exists := sm.lookupTransition(step.Object.State, step.To)
+		if !exists {
+			return fmt.Errorf("step %d: invalid transition from %s to %s", i, step.Object.State, step.To)
+		}
+		pending = append(pending, &pendingStep{Step: step, transition: transition})
+	}
+
+	var processed []string
+	for i, p := range pending {
+		so := p.Object
+		sm.Log("Starting transition of", so.ID, "from", so.State, "to", p.To)
+		p.tc = &TransitionContext{
+			Machine: sm,
+			Object:  so,
+			From:    so.State,
+			To:      p.To,
+			Event:   p.transition.Event,
+			Kind:    p.transition.Kind,
+			Log:     &StateTransitionLog{FromState: so.State, ToState: p.To, Timestamp: nowFunc()},
+		}
+		if p.transition.Guard != nil && !p.transition.Guard(p.tc) {
+			err := &GuardError{Guard: p.transition.GuardName, From: so.State, To: p.To}
+			return &TransitionAllError{Step: i, Err: err, Rollback: sm.undoSteps(ctx, pending[:i])}
+		}
+		if !p.transition.SkipDataSnapshot {
+			p.restore = so.snapshotData()
+		}
+
+		handlers, marks := withoutSlot(p.transition.handlerList(), SlotMarkProcessed)
+		executed, err := so.executeChain(ctx, sm, p.transition, p.tc, handlers)
+		if err != nil {
+			// A failed chain rolled itself back. A duplicate did not, and
+			// is undone with the other steps.
+			undo := pending[:i]
+			if errors.Is(err, ErrAlreadyProcessed) {
+				p.executed = executed
+				undo = pending[:i+1]
+			}
+			return &TransitionAllError{Step: i, Err: err, Rollback: sm.undoSteps(ctx, undo)}
+		}
+		p.executed = executed
+		if marks && so.EventID != "" {
+			processed = append(processed, so.EventID)
+		}
+	}
+
+	commit := AtomicCommit{Objects: make(map[string][]byte, len(pending)), Processed: processed}
+	for _, p := range pending {
+		next := *p.Object
+		next.State = p.To
+		serialized, err := sm.encode(&next)
+		if err != nil {
+			return &TransitionAllError{Step: -1, Err: err, Rollback: sm.undoSteps(ctx, pending)}
+		}
+		commit.Objects[next.storageKey()] = serialized
+	}
+
+	store := sm.atomicStore
+	if store == nil {
+		store = NewRedisAtomicStore(sm.redisClient)
+	}
+	if err := store.Commit(ctx, commit); err != nil {
+		sm.LogErr(fmt.Errorf("Commit of %d transitions failed: %w", len(pending), err))
+		return &TransitionAllError{Step: -1, Err: err, Rollback: sm.undoSteps(ctx, pending)}
+	}
+
+	for _, p := range pending {
+		so := p.Object
+		sm.Log("Successfully concluded transition of", so.ID, "from", so.State, "to", p.To)
+		sm.runHooks(sm.onExit[so.State], so)
+		so.State = p.To
+		sm.runHooks(sm.onEnter[p.To], so)
+		p.tc.Log.EventID = so.EventID
+		so.logTransition(*p.tc.Log, sm)
+	}
+	return nil
+}
+
+// undoSteps rolls back the executed handlers of the steps in reverse order
+// and restores their Data. A step whose rollback fails under
+// RollbackEscalate is moved to ManualReview. It returns the rollback
+// failures.
+func (sm *StateMachine) undoSteps(ctx context.Context, steps []*pendingStep) error {
+	var rollbackErrs []error
+	for i := len(steps) - 1; i >= 0; i-- {
+		p := steps[i]
+		err := p.Object.rollback(ctx, sm, p.transition.RollbackPolicy, p.tc, nil, p.executed, nil)
+		if p.restore != nil {
+			p.restore()
+		}
+		if err == nil {
+			continue
+		}
+		rollbackErrs = append(rollbackErrs, fmt.Errorf("object %s: %w", p.Object.ID, err))
+		if p.transition.RollbackPolicy == RollbackEscalate {
+			p.Object.State = ManualReview
+		}
+	}
+
+	switch len(rollbackErrs) {
+	case 0:
+		return nil
+	case 1:
+		return rollbackErrs[0]
+	default:
+		return rollbackFailures(rollbackErrs)
+	}
+}
+
+// withoutSlot returns the handlers without those in the given slot, and
+// whether any were left out.
+func withoutSlot(handlers []Handler, slot string) ([]Handler, bool) {
+	kept := make([]Handler, 0, len(handlers))
+	for _, handler := range handlers {
+		if handlerSlot(handler) == slot {
+			continue
+		}
+		kept = append(kept, handler)
+	}
+	return kept, len(kept) != len(handlers)
+}
+
+// NewRedisAtomicStore returns an AtomicStore that commits with a Lua script,
+// which Redis runs atomically. Objects are stored under their storage key
+// and processed markers under the event ID, like CommitToDisk and the
+// markprocessed handler do.
+func NewRedisAtomicStore(client *redis.Client) AtomicStore {
+	return redisAtomicStore{client: client}
+}
+
+type redisAtomicStore struct {
+	client *redis.Client
+}
+
+// atomicCommitScript writes nothing and returns 0 when one of the event IDs
+// is already marked processed. KEYS are the storage keys of the objects
+// followed by the event IDs; ARGV are the number of objects followed by
+// their serialized data.
+var atomicCommitScript = redis.NewScript(`
+local objects = tonumber(ARGV[1])
+for i = objects + 1, #KEYS do
+	if redis.call("EXISTS", KEYS[i]) == 1 then
+		return 0
+	end
+end
+for i = 1, objects do
+	redis.call("SET", KEYS[i], ARGV[i + 1])
+end
+for i = objects + 1, #KEYS do
+	redis.call("SET", KEYS[i], "1")
+end
+return 1
+`)
+
+func (s redisAtomicStore) Commit(ctx context.Context, commit AtomicCommit) error {
+	keys := make([]string, 0, len(commit.Objects)+len(commit.Processed))
+	args := make([]interface{}, 0, len(commit.Objects)+1)
+	args = append(args, len(commit.Objects))
+	for key, data := range commit.Objects {
+		keys = append(keys, key)
+		args = append(args, data)
+	}
+	keys = append(keys, commit.Processed...)
+
+	committed, err := atomicCommitScript.Run(ctx, s.client, keys, args...).Int()
+	if err != nil {
+		return err
+	}
+	if committed == 0 {
+		return ErrAlreadyProcessed
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+const SIMRetired = "SIMRetired"
+
+type recordingAtomicStore struct {
+	err     error
+	commits []AtomicCommit
+}
+
+func (s *recordingAtomicStore) Commit(ctx context.Context, commit AtomicCommit) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.commits = append(s.commits, commit)
+	return nil
+}
+
+type swapFixture struct {
+	sm       *StateMachine
+	store    *recordingAtomicStore
+	oldSIM   *StateObject
+	newSIM   *StateObject
+	retire   *mockContextHandler
+	activate *mockContextHandler
+	marked   int
+}
+
+func newSwapFixture(t *testing.T, activateErr error) *swapFixture {
+	f := &swapFixture{
+		sm:       newTestStateMachine(),
+		store:    &recordingAtomicStore{},
+		retire:   &mockContextHandler{},
+		activate: &mockContextHandler{err: activateErr},
+	}
+	f.sm.SetHandlerConfig(HandlerConfig{})
+	f.sm.SetAtomicStore(f.store)
+
+	setEventID := NewHandler(SlotEventID, func(ctx context.Context, tc *TransitionContext) error {
+		tc.Object.EventID = tc.Object.ID + ":" + tc.To
+		return nil
+	}, nil)
+	markProcessed := NewHandler(SlotMarkProcessed, func(ctx context.Context, tc *TransitionContext) error {
+		f.marked++
+		return nil
+	}, nil)
+	f.sm.RegisterTransition(SIMActivated, SIMRetired, setEventID, FromContextHandler(f.retire), markProcessed)
+	f.sm.RegisterTransition(SIMNotActivated, SIMActivated, setEventID, FromContextHandler(f.activate), markProcessed)
+
+	logger := zaptest.NewLogger(t)
+	f.oldSIM = NewStateObject(map[string]interface{}{"iccid": "8901"}, f.sm, logger)
+	f.oldSIM.ID = "sim-old"
+	f.oldSIM.State = SIMActivated
+	f.newSIM = NewStateObject(map[string]interface{}{"iccid": "8902"}, f.sm, logger)
+	f.newSIM.ID = "sim-new"
+	return f
+}
+
+func (f *swapFixture) swap() error {
+	return f.sm.TransitionAll(context.Background(), []Step{
+		{Object: f.oldSIM, To: SIMRetired},
+		{Object: f.newSIM, To: SIMActivated},
+	})
+}
+
+func TestTransitionAllCommitsTogether(t *testing.T) {
+	f := newSwapFixture(t, nil)
+	if err := f.swap(); err != nil {
+		t.Fatalf("TransitionAll failed: %v", err)
+	}
+	if f.oldSIM.State != SIMRetired || f.newSIM.State != SIMActivated {
+		t.Errorf("Expected both SIMs to be transitioned but got %s and %s", f.oldSIM.State, f.newSIM.State)
+	}
+	if len(f.store.commits) != 1 {
+		t.Fatalf("Expected a single commit but got %d", len(f.store.commits))
+	}
+
+	commit := f.store.commits[0]
+	stored, err := Deserialize(commit.Objects["state:sim-new"])
+	if err != nil {
+		t.Fatalf("Failed to decode committed object: %v", err)
+	}
+	if stored.State != SIMActivated {
+		t.Errorf("Expected the committed object to be in %s but got %s", SIMActivated, stored.State)
+	}
+	if len(commit.Processed) != 2 || commit.Processed[0] != "sim-old:"+SIMRetired || commit.Processed[1] != "sim-new:"+SIMActivated {
+		t.Errorf("Expected both event IDs to be marked processed but got %v", commit.Processed)
+	}
+	if f.marked != 0 {
+		t.Errorf("Expected the markprocessed handler to be left to the commit but it ran %d times", f.marked)
+	}
+}
+
+func TestTransitionAllRollsBackWhenAStepFails(t *testing.T) {
+	f := newSwapFixture(t, errCarrierUnavailable)
+	err := f.swap()
+
+	var allErr *TransitionAllError
+	if !errors.As(err, &allErr) {
+		t.Fatalf("Expected a *TransitionAllError but got %v", err)
+	}
+	if allErr.Step != 1 || !errors.Is(err, errCarrierUnavailable) {
+		t.Errorf("Expected step 1 to be reported but got %v", err)
+	}
+	if f.retire.rolledBack != 1 {
+		t.Errorf("Expected the completed step to be rolled back")
+	}
+	if f.oldSIM.State != SIMActivated || f.newSIM.State != SIMNotActivated {
+		t.Errorf("Expected both SIMs to keep their states but got %s and %s", f.oldSIM.State, f.newSIM.State)
+	}
+	if len(f.store.commits) != 0 {
+		t.Errorf("Expected nothing to be committed")
+	}
+}
+
+func TestTransitionAllRollsBackWhenTheCommitFails(t *testing.T) {
+	errStoreDown := errors.New("store down")
+	f := newSwapFixture(t, nil)
+	f.store.err = errStoreDown
+	err := f.swap()
+
+	var allErr *TransitionAllError
+	if !errors.As(err, &allErr) || allErr.Step != -1 || !errors.Is(err, errStoreDown) {
+		t.Fatalf("Expected the commit failure to be reported but got %v", err)
+	}
+	if f.retire.rolledBack != 1 || f.activate.rolledBack != 1 {
+		t.Errorf("Expected every step to be rolled back")
+	}
+	if f.oldSIM.State != SIMActivated || f.newSIM.State != SIMNotActivated {
+		t.Errorf("Expected both SIMs to keep their states but got %s and %s", f.oldSIM.State, f.newSIM.State)
+	}
+}
+
+func TestTransitionAllRollsBackWhenTheCommitFindsAProcessedEvent(t *testing.T) {
+	f := newSwapFixture(t, nil)
+	f.store.err = ErrAlreadyProcessed
+	err := f.swap()
+
+	var allErr *TransitionAllError
+	if !errors.As(err, &allErr) || allErr.Step != -1 || !errors.Is(err, ErrAlreadyProcessed) {
+		t.Fatalf("Expected the processed event to be reported but got %v", err)
+	}
+	if f.retire.rolledBack != 1 || f.activate.rolledBack != 1 {
+		t.Errorf("Expected every step to be rolled back")
+	}
+	if f.oldSIM.State != SIMActivated || f.newSIM.State != SIMNotActivated {
+		t.Errorf("Expected both SIMs to keep their states but got %s and %s", f.oldSIM.State, f.newSIM.State)
+	}
+}
+
+func TestTransitionAllEscalatesFailedRollback(t *testing.T) {
+	f := newSwapFixture(t, errCarrierUnavailable)
+	f.retire.rollbackErr = errors.New("cannot reactivate")
+	err := f.swap()
+
+	if !errors.Is(err, ErrRollbackFailed) {
+		t.Errorf("Expected a rollback failure but got %v", err)
+	}
+	if f.oldSIM.State != ManualReview {
+		t.Errorf("Expected %s but got %s", ManualReview, f.oldSIM.State)
+	}
+}
+
+func TestTransitionAllValidatesSteps(t *testing.T) {
+	f := newSwapFixture(t, nil)
+	f.newSIM.ID = ""
+	if err := f.swap(); !errors.Is(err, ErrMissingObjectID) {
+		t.Errorf("Expected ErrMissingObjectID but got %v", err)
+	}
+
+	f.newSIM.ID = "sim-new"
+	err := f.sm.TransitionAll(context.Background(), []Step{{Object: f.newSIM, To: SIMRetired}})
+	if err == nil {
+		t.Errorf("Expected an invalid transition to be rejected")
+	}
+	if f.activate.handled != 0 || f.retire.handled != 0 {
+		t.Errorf("Expected no handler to run for invalid steps")
+	}
+}
@@ -0,0 +1,144 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// TypedStateObject is a StateObject whose data is a value of type T instead
+// of a map. Handlers built with TypedHandler receive a *T pointing to Data,
+// so nothing goes through map conversions or type assertions. It is
+// transitioned, committed and serialized like any StateObject.
+type TypedStateObject[T any] struct {
+	*StateObject
+	Data T
+}
+
+// NewTypedStateObject creates a TypedStateObject holding the data.
+func NewTypedStateObject[T any](data T, sm *StateMachine, logger *zap.Logger) *TypedStateObject[T] {
+	so := &TypedStateObject[T]{Data: data}
+	so.StateObject = NewStateObject(nil, sm, logger)
+	so.StateObject.typed = &so.Data
+	return so
+}
+
+// TypedHandler is a ContextHandler for TypedStateObjects with data of type
+// T.
+type TypedHandler[T any] interface {
+	Handle(ctx context.Context, tc *TransitionContext, data *T) error
+	Rollback(ctx context.Context, tc *TransitionContext, data *T) error
+}
+
+// TypedHandlerFunc handles or rolls back a transition of a TypedStateObject.
+type TypedHandlerFunc[T any] func(ctx context.Context, tc *TransitionContext, data *T) error
+
+// NewTypedHandler builds a Handler from functions receiving the typed data
+// of the object, like NewHandler.
+func NewTypedHandler[T any](name string, handle, rollback TypedHandlerFunc[T]) Handler {
+	return FromTypedHandler[T](&typedFuncHandler[T]{name: name, handle: handle, rollback: rollback})
+}
+
+type typedFuncHandler[T any] struct {
+	name     string
+	handle   TypedHandlerFunc[T]
+	rollback TypedHandlerFunc[T]
+}
+
+func (h *typedFuncHandler[T]) Handle(ctx context.Context, tc *TransitionContext, data *T) error {
+	if h.handle == nil {
+		return nil
+	}
+	return h.handle(ctx, tc, data)
+}
+
+func (h *typedFuncHandler[T]) Rollback(ctx context.Context, tc *TransitionContext, data *T) error {
+	if h.rollback == nil {
+		return nil
+	}
+	return h.rollback(ctx, tc, data)
+}
+
+func (h *typedFuncHandler[T]) Name() string {
+	return h.name
+}
+
+// FromTypedHandler turns a TypedHandler into a Handler that can be
+// registered with a transition. It fails transitions of objects that are
+// not a TypedStateObject[T].
+func FromTypedHandler[T any](h TypedHandler[T]) Handler {
+	return FromContextHandler(&typedContextHandler[T]{handler: h})
+}
+
+type typedContextHandler[T any] struct {
+	handler TypedHandler[T]
+}
+
+func (h *typedContextHandler[T]) Handle(ctx context.Context, tc *TransitionContext) error {
+	data, err := typedData[T](tc.Object)
+	if err != nil {
+		return err
+	}
+	return h.handler.Handle(ctx, tc, data)
+}
+
+func (h *typedContextHandler[T]) Rollback(ctx context.Context, tc *TransitionContext) error {
+	data, err := typedData[T](tc.Object)
+	if err != nil {
+		return err
+	}
+	return h.handler.Rollback(ctx, tc, data)
+}
+
+// Name reports the slot of the wrapped handler when it implements Named.
+func (h *typedContextHandler[T]) Name() string {
+	if named, ok := h.handler.(Named); ok {
+		return named.Name()
+	}
+	return ""
+}
+
+func typedData[T any](so *StateObject) (*T, error) {
+	data, ok := so.typed.(*T)
+	if !ok {
+		return nil, fmt.Errorf("%w: object has no typed data of type %T", ErrHandlerFailed, (*T)(nil))
+	}
+	return data, nil
+}
+
+// IntoDeserializer is implemented by a Serialization that can decode into
+// an existing StateObject. TypedStateObjects are then decoded straight into
+// their typed data.
+type IntoDeserializer interface {
+	DeserializeInto([]byte, *StateObject) error
+}
+
+// DeserializeTyped decodes a stored TypedStateObject with the serializer
+// named by its content type. Serializers that don't implement
+// IntoDeserializer are decoded into a map first and converted.
+func DeserializeTyped[T any](sm *StateMachine, data []byte) (*TypedStateObject[T], error) {
+	var zero T
+	so := NewTypedStateObject(zero, sm, nil)
+	serializer, payload, err := decodeBlob(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if into, ok := serializer.(IntoDeserializer); ok {
+		if err := into.DeserializeInto(payload, so.StateObject); err != nil {
+			return nil, err
+		}
+		return so, nil
+	}
+
+	decoded, err := serializer.Deserialize(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := decoded.DecodeDataToObject(&so.Data); err != nil {
+		return nil, err
+	}
+	so.ID, so.State, so.EventID = decoded.ID, decoded.State, decoded.EventID
+	return so, nil
+}
@@ -0,0 +1,101 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+type simCard struct {
+	ICCID    string   `json:"iccid"`
+	IMSI     int64    `json:"imsi"`
+	Carriers []string `json:"carriers"`
+}
+
+func TestTypedHandlerReceivesData(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, NewTypedHandler("", func(ctx context.Context, tc *TransitionContext, sim *simCard) error {
+		sim.Carriers = append(sim.Carriers, "TelecomProvider")
+		return nil
+	}, nil))
+
+	sim := NewTypedStateObject(simCard{ICCID: "8901", IMSI: 310150123456789}, sm, zaptest.NewLogger(t))
+	if err := sim.TransitionTo(sm, SIMActivated); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+	if sim.State != SIMActivated || len(sim.Data.Carriers) != 1 {
+		t.Errorf("Expected the handler to update the typed data but got %+v", sim.Data)
+	}
+}
+
+func TestTypedDataRestoredOnFailure(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	update := NewTypedHandler("", func(ctx context.Context, tc *TransitionContext, sim *simCard) error {
+		sim.Carriers[0] = "OtherProvider"
+		sim.IMSI = 0
+		return nil
+	}, nil)
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, update, FromContextHandler(&mockContextHandler{err: errCarrierUnavailable}))
+
+	sim := NewTypedStateObject(simCard{IMSI: 310150123456789, Carriers: []string{"TelecomProvider"}}, sm, zaptest.NewLogger(t))
+	if err := sim.TransitionTo(sm, SIMActivated); !errors.Is(err, errCarrierUnavailable) {
+		t.Fatalf("Expected the transition to fail but got %v", err)
+	}
+	if sim.Data.IMSI != 310150123456789 || sim.Data.Carriers[0] != "TelecomProvider" {
+		t.Errorf("Expected the typed data to be restored but got %+v", sim.Data)
+	}
+}
+
+func TestTypedHandlerNeedsTypedObject(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.RegisterTransition(SIMNotActivated, SIMActivated, NewTypedHandler[simCard]("", nil, nil))
+
+	so := NewStateObject(map[string]interface{}{}, sm, zaptest.NewLogger(t))
+	if err := so.TransitionTo(sm, SIMActivated); !errors.Is(err, ErrHandlerFailed) {
+		t.Errorf("Expected a typed handler to fail on a plain object but got %v", err)
+	}
+}
+
+// mapOnlySerialization can't decode into an existing object.
+type mapOnlySerialization struct{}
+
+func (mapOnlySerialization) Serialize(so *StateObject) ([]byte, error) {
+	return JSONSerialization{}.Serialize(so)
+}
+
+func (mapOnlySerialization) Deserialize(data []byte) (*StateObject, error) {
+	return JSONSerialization{}.Deserialize(data)
+}
+
+func TestDeserializeTyped(t *testing.T) {
+	for name, serializer := range map[string]Serialization{
+		"into": JSONSerialization{},
+		"map":  mapOnlySerialization{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sm := newTestStateMachine()
+			sm.SetHandlerConfig(HandlerConfig{Serializer: serializer})
+
+			sim := NewTypedStateObject(simCard{ICCID: "8901", IMSI: 310150123456789}, sm, nil)
+			sim.ID = "sim-8901"
+			sim.State = SIMActivated
+			data, err := sm.encode(sim.StateObject)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			decoded, err := DeserializeTyped[simCard](sm, data)
+			if err != nil {
+				t.Fatalf("DeserializeTyped failed: %v", err)
+			}
+			if decoded.Data.ICCID != "8901" || decoded.Data.IMSI != 310150123456789 || decoded.ID != sim.ID || decoded.State != SIMActivated {
+				t.Errorf("Expected %+v but got %+v", sim.Data, decoded.Data)
+			}
+		})
+	}
+}
@@ -1,14 +1,32 @@
 package statemachine
 
 import (
+	"context"
 	"database/sql"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
 type StateMachine struct {
-	transitions    map[string]StateTransition
+	transitions    map[string]*StateTransition
+	sourceSets     map[string]*StateTransition
+	wildcards      map[string]*StateTransition
+	onEnter        map[string][]func(*StateObject)
+	onExit         map[string][]func(*StateObject)
+	internal       map[string]*StateTransition
+	events         map[string][]*StateTransition
+	states         map[string]bool
+	initial        string
+	guards         map[string]Guard
+	namedHandlers  map[string]Handler
+	handlerEdits   []handlerEdit
+	middleware     []Middleware
+	breakers       map[string]*CircuitBreaker
+	breakersMu     sync.Mutex
+	alert          AlertFunc
+	atomicStore    AtomicStore
 	config         HandlerConfig
 	LogTransitions bool
 	DebugLogging   bool
@@ -28,9 +46,29 @@ type Config struct {
 }
 
 type StateTransition struct {
-	From  string
-	To    string
-	Chain Handler
+	From string
+	To   string
+	// Chain is the first handler, linked to the others through SetNext for
+	// code that walks a chain by hand. A handler instance has a single
+	// successor, so the links are only valid when none of the handlers is
+	// shared with a transition registered later. The machine runs Handlers.
+	Chain    Handler
+	Handlers []Handler
+	Sources  []string
+	Except   []string
+	Kind     TransitionKind
+	Event    string
+
+	RollbackPolicy   RollbackPolicy
+	SkipDataSnapshot bool
+	Timeout          time.Duration
+
+	// Guard must allow the transition before its handlers run. GuardName
+	// is the name it was registered under, if any.
+	Guard     Guard
+	GuardName string
+
+	middleware []Middleware
 }
 
 type Handler interface {
@@ -40,6 +78,55 @@ type Handler interface {
 	Next() Handler
 }
 
+// ContextHandler is the context-aware handler interface. Handlers report why
+// they failed through the returned error, and receive a context carrying the
+// cancellation and deadline of the transition.
+type ContextHandler interface {
+	Handle(context.Context, *TransitionContext) error
+	Rollback(context.Context, *TransitionContext) error
+}
+
+// TransitionContext describes the transition a ContextHandler is part of.
+type TransitionContext struct {
+	Machine *StateMachine
+	Object  *StateObject
+	From    string
+	To      string
+	Event   string
+	Kind    TransitionKind
+	Log     *StateTransitionLog
+
+	mu sync.Mutex
+	// transition is the transition whose chain is running, so Parallel
+	// groups can wrap their members with its middleware.
+	transition *StateTransition
+	// completed holds the members of each Parallel group that completed
+	// and were not rolled back yet.
+	completed map[*parallelHandler][]Handler
+}
+
+// Lock guards the transition while handlers of a Parallel group run at the
+// same time. Members must hold it while they read or update Object.
+func (tc *TransitionContext) Lock() {
+	tc.mu.Lock()
+}
+
+func (tc *TransitionContext) Unlock() {
+	tc.mu.Unlock()
+}
+
+// Guard decides whether a transition may run for the object.
+type Guard func(*TransitionContext) bool
+
+// AlertFunc is called to alert on a failure of a transition.
+type AlertFunc func(ctx context.Context, tc *TransitionContext, err error)
+
+// Named is implemented by handlers that occupy a named slot in the chain.
+// A custom handler named after a default slot replaces the default handler.
+type Named interface {
+	Name() string
+}
+
 type Serialization interface {
 	Serialize(*StateObject) ([]byte, error)
 	Deserialize([]byte) (*StateObject, error)
@@ -59,4 +146,16 @@ type StateTransitionLog struct {
 	FromState string
 	ToState   string
 	Timestamp time.Time
+	Attempts  []HandlerAttempt
+	// Err is the error the handler chain failed with. It is nil for a
+	// concluded transition.
+	Err error
+}
+
+// HandlerAttempt records one call of a handler that has a retry policy.
+type HandlerAttempt struct {
+	Handler   string
+	Attempt   int
+	Err       error
+	Timestamp time.Time
 }
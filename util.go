@@ -1,19 +1,30 @@
 package statemachine
 
-import (
-	"encoding/json"
-)
-
+// Deserialize decodes a stored object with the serializer named by its
+// content type. Objects stored without one are read as JSON.
 func Deserialize(data []byte) (*StateObject, error) {
-	var so StateObject
-	err := json.Unmarshal(data, &so)
-	return &so, err
+	serializer, payload, err := decodeBlob(data)
+	if err != nil {
+		return nil, err
+	}
+	return serializer.Deserialize(payload)
 }
 
-// Helper functions for creating chains
+// Helper functions for creating chains. The engine runs the handlers of a
+// transition in order itself; the links set here only keep Next working for
+// code that walks a chain by hand.
 func CreateHandlerChain(handlers ...Handler) Handler {
 	for i := 0; i < len(handlers)-1; i++ {
 		handlers[i].SetNext(handlers[i+1])
 	}
 	return handlers[0]
 }
+
+func containsState(states []string, state string) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package statemachine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidationIssueKind is the kind of modelling mistake found by Validate.
+type ValidationIssueKind string
+
+const (
+	// UnreachableState is a state no transition leads to from the initial
+	// state.
+	UnreachableState ValidationIssueKind = "unreachable"
+	// DeadEndState is a state that isn't final but has no transition out.
+	DeadEndState ValidationIssueKind = "dead-end"
+	// UndeclaredState is a state used by a transition but not declared.
+	UndeclaredState ValidationIssueKind = "undeclared"
+	// ConflictingTransition is a transition for an event that can never
+	// run, because an earlier one for the same state and event has no guard
+	// or the same guard.
+	ConflictingTransition ValidationIssueKind = "conflicting"
+	// NoRecoveryPath is a state from which ManualReview can't be reached.
+	NoRecoveryPath ValidationIssueKind = "no-recovery"
+)
+
+// ValidationIssue is a modelling mistake in the transitions of a machine.
+type ValidationIssue struct {
+	Kind    ValidationIssueKind
+	State   string
+	Message string
+}
+
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Kind, i.Message)
+}
+
+// ValidationError is returned by Validate with every issue it found.
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	issues := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		issues[i] = issue.String()
+	}
+	return "invalid state machine: " + strings.Join(issues, "; ")
+}
+
+// Validate analyses the registered transitions and reports states that
+// can't be reached from the initial state, non-final states with no way
+// out, transitions using undeclared states, event transitions that can
+// never run, and states from which ManualReview can't be reached. States
+// only need to be declared for the undeclared check to apply. ManualReview
+// is entered on failed rollbacks without a transition, so it counts as
+// reachable and may be a dead end.
+func (sm *StateMachine) Validate() error {
+	g := sm.graph()
+	var issues []ValidationIssue
+
+	if len(sm.states) > 0 {
+		for _, state := range g.states {
+			if _, declared := sm.states[state]; !declared {
+				issues = append(issues, ValidationIssue{
+					Kind:    UndeclaredState,
+					State:   state,
+					Message: fmt.Sprintf("state %s is used by a transition but not declared", state),
+				})
+			}
+		}
+	}
+
+	if initial := sm.initialState(); g.has(initial) {
+		reachable := g.reachableFrom(initial)
+		for _, state := range g.states {
+			if !reachable[state] && state != ManualReview {
+				issues = append(issues, ValidationIssue{
+					Kind:    UnreachableState,
+					State:   state,
+					Message: fmt.Sprintf("state %s can't be reached from the initial state %s", state, initial),
+				})
+			}
+		}
+	}
+
+	for _, state := range g.states {
+		if len(g.out[state]) == 0 && !sm.states[state] && state != ManualReview {
+			issues = append(issues, ValidationIssue{
+				Kind:    DeadEndState,
+				State:   state,
+				Message: fmt.Sprintf("state %s is not final but has no transition out", state),
+			})
+		}
+	}
+
+	issues = append(issues, sm.conflictingTransitions()...)
+
+	recoverable := g.reaching(ManualReview)
+	for _, state := range g.states {
+		if !recoverable[state] && !sm.states[state] && state != ManualReview {
+			issues = append(issues, ValidationIssue{
+				Kind:    NoRecoveryPath,
+				State:   state,
+				Message: fmt.Sprintf("state %s has no path to %s", state, ManualReview),
+			})
+		}
+	}
+
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
+
+// conflictingTransitions reports event transitions shadowed by an earlier
+// one for the same state and event.
+func (sm *StateMachine) conflictingTransitions() []ValidationIssue {
+	keys := make([]string, 0, len(sm.events))
+	for key := range sm.events {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var issues []ValidationIssue
+	for _, key := range keys {
+		transitions := sm.events[key]
+		for i, later := range transitions {
+			for _, earlier := range transitions[:i] {
+				if earlier.Guard != nil && earlier.GuardName != later.GuardName {
+					continue
+				}
+				reason := "an unguarded transition"
+				if earlier.Guard != nil {
+					reason = "a transition with the same guard " + earlier.GuardName
+				}
+				issues = append(issues, ValidationIssue{
+					Kind:  ConflictingTransition,
+					State: earlier.From,
+					Message: fmt.Sprintf("event %s from %s to %s never runs, %s to %s comes first",
+						later.Event, later.From, later.To, reason, earlier.To),
+				})
+				break
+			}
+		}
+	}
+	return issues
+}
+
+// transitionGraph is the graph of states connected by the registered
+// transitions. Self and internal transitions don't add edges.
+type transitionGraph struct {
+	states []string
+	out    map[string]map[string]bool
+}
+
+func (sm *StateMachine) graph() *transitionGraph {
+	known := make(map[string]bool)
+	for state := range sm.states {
+		known[state] = true
+	}
+	addStates := func(states ...string) {
+		for _, state := range states {
+			if state != AnyState {
+				known[state] = true
+			}
+		}
+	}
+	for _, t := range sm.transitions {
+		addStates(t.From, t.To)
+	}
+	for _, t := range sm.sourceSets {
+		addStates(t.Sources...)
+		addStates(t.To)
+		addStates(t.Except...)
+	}
+	for _, t := range sm.wildcards {
+		addStates(t.To)
+		addStates(t.Except...)
+	}
+	for _, t := range sm.internal {
+		addStates(t.From)
+	}
+
+	g := &transitionGraph{out: make(map[string]map[string]bool)}
+	for state := range known {
+		g.states = append(g.states, state)
+	}
+	sort.Strings(g.states)
+
+	addEdge := func(from, to string) {
+		if from == to {
+			return
+		}
+		if g.out[from] == nil {
+			g.out[from] = make(map[string]bool)
+		}
+		g.out[from][to] = true
+	}
+	for _, t := range sm.transitions {
+		addEdge(t.From, t.To)
+	}
+	for _, t := range sm.sourceSets {
+		for _, source := range t.Sources {
+			addEdge(source, t.To)
+		}
+	}
+	for _, t := range sm.wildcards {
+		for _, state := range g.states {
+			if !containsState(t.Except, state) {
+				addEdge(state, t.To)
+			}
+		}
+	}
+	for _, t := range sm.eventTransitions() {
+		if t.From != AnyState {
+			addEdge(t.From, t.To)
+			continue
+		}
+		for _, state := range g.states {
+			if !containsState(t.Except, state) {
+				addEdge(state, t.To)
+			}
+		}
+	}
+	return g
+}
+
+func (g *transitionGraph) has(state string) bool {
+	i := sort.SearchStrings(g.states, state)
+	return i < len(g.states) && g.states[i] == state
+}
+
+// reachableFrom returns the states reachable from the given one.
+func (g *transitionGraph) reachableFrom(state string) map[string]bool {
+	reached := map[string]bool{state: true}
+	queue := []string{state}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for next := range g.out[current] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reached
+}
+
+// reaching returns the states the given one can be reached from.
+func (g *transitionGraph) reaching(state string) map[string]bool {
+	reached := map[string]bool{state: true}
+	for changed := true; changed; {
+		changed = false
+		for _, from := range g.states {
+			if reached[from] {
+				continue
+			}
+			for to := range g.out[from] {
+				if reached[to] {
+					reached[from] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return reached
+}
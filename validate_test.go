@@ -0,0 +1,87 @@
+package statemachine
+
+import (
+	"errors"
+	"testing"
+)
+
+func newLifecycleStateMachine() *StateMachine {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.SetInitialState(SIMNotActivated)
+	sm.DeclareState(SIMActivated)
+	sm.DeclareFinalState(SIMRetired)
+	sm.DeclareState(ManualReview)
+	sm.RegisterEventTransition(SIMNotActivated, "activate", SIMActivated).WithGuard("hasCarrier", hasCarrier)
+	sm.RegisterTransition(SIMActivated, SIMRetired)
+	sm.RegisterTransitionFromAnyExcept([]string{SIMRetired}, ManualReview)
+	return sm
+}
+
+func validationIssues(t *testing.T, sm *StateMachine) map[ValidationIssueKind][]string {
+	t.Helper()
+	err := sm.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a *ValidationError but got %v", err)
+	}
+	issues := make(map[ValidationIssueKind][]string)
+	for _, issue := range validationErr.Issues {
+		issues[issue.Kind] = append(issues[issue.Kind], issue.State)
+	}
+	return issues
+}
+
+func TestValidateAcceptsWellFormedMachine(t *testing.T) {
+	if err := newLifecycleStateMachine().Validate(); err != nil {
+		t.Errorf("Expected no issues but got %v", err)
+	}
+}
+
+func TestValidateReportsUnreachableAndDeadEndStates(t *testing.T) {
+	sm := newLifecycleStateMachine()
+	sm.DeclareState(SIMDeactivated)
+	sm.RegisterTransition(SIMDeactivated, SIMRetired)
+	sm.RegisterTransition(SIMActivated, PhoneNumberRecycled)
+	sm.RegisterTransitionFromAnyExcept([]string{SIMRetired, PhoneNumberRecycled}, ManualReview)
+
+	issues := validationIssues(t, sm)
+	if states := issues[UnreachableState]; len(states) != 1 || states[0] != SIMDeactivated {
+		t.Errorf("Expected %s to be unreachable but got %v", SIMDeactivated, states)
+	}
+	if states := issues[DeadEndState]; len(states) != 1 || states[0] != PhoneNumberRecycled {
+		t.Errorf("Expected %s to be a dead end but got %v", PhoneNumberRecycled, states)
+	}
+	if states := issues[UndeclaredState]; len(states) != 1 || states[0] != PhoneNumberRecycled {
+		t.Errorf("Expected %s to be undeclared but got %v", PhoneNumberRecycled, states)
+	}
+}
+
+func TestValidateReportsConflictingTransitions(t *testing.T) {
+	sm := newLifecycleStateMachine()
+	sm.RegisterEventTransition(SIMNotActivated, "activate", SIMRetired).WithGuard("hasCarrier", hasCarrier)
+	sm.RegisterEventTransition(SIMActivated, "retire", SIMRetired)
+	sm.RegisterEventTransition(SIMActivated, "retire", ManualReview)
+
+	issues := validationIssues(t, sm)
+	if states := issues[ConflictingTransition]; len(states) != 2 {
+		t.Errorf("Expected two conflicting transitions but got %v", states)
+	}
+}
+
+func TestValidateReportsMissingRecoveryPath(t *testing.T) {
+	sm := newTestStateMachine()
+	sm.SetHandlerConfig(HandlerConfig{})
+	sm.SetInitialState(SIMNotActivated)
+	sm.DeclareState(SIMActivated)
+	sm.RegisterTransition(SIMNotActivated, SIMActivated)
+	sm.RegisterTransition(SIMActivated, SIMNotActivated)
+
+	issues := validationIssues(t, sm)
+	if states := issues[NoRecoveryPath]; len(states) != 2 {
+		t.Errorf("Expected both states to have no path to %s but got %v", ManualReview, states)
+	}
+	if len(issues[DeadEndState]) != 0 || len(issues[UnreachableState]) != 0 {
+		t.Errorf("Expected only missing recovery paths but got %v", issues)
+	}
+}